@@ -0,0 +1,103 @@
+package llmclient
+
+// maxStreamChoices - наибольшее число вариантов ответа (предел параметра n у OpenAI).
+// Дельты с индексом варианта вне [0, maxStreamChoices) игнорируются
+const maxStreamChoices = 128
+
+// StreamAccumulator собирает фрагменты потока в ChatResponse того же вида,
+// что возвращает Chat: роль, текст и рассуждения (ReasoningContent) склеиваются
+// по отдельности, части вызовов инструментов объединяются по индексу, usage
+// берется из завершающего фрагмента. Дельты с некорректным индексом варианта
+// пропускаются.
+// Подходит для передачи фрагментов прямо из обработчика ChatStream
+type StreamAccumulator struct {
+	resp ChatResponse
+	// toolCalls сопоставляет индекс вызова в потоке с позицией в ToolCalls варианта
+	toolCalls []map[int]int
+}
+
+// Add добавляет очередной фрагмент потока
+func (a *StreamAccumulator) Add(chunk ChatStreamChunk) {
+	if chunk.Model != "" {
+		a.resp.Model = chunk.Model
+	}
+	if chunk.ServiceTier != "" {
+		a.resp.ServiceTier = chunk.ServiceTier
+	}
+	if chunk.Usage != nil {
+		a.resp.Usage = *chunk.Usage
+	}
+
+	for _, delta := range chunk.Choices {
+		if delta.Index < 0 || delta.Index >= maxStreamChoices {
+			continue
+		}
+		choice := a.choice(delta.Index)
+
+		if delta.Delta.Role != "" {
+			choice.Message.Role = delta.Delta.Role
+		}
+		choice.Message.Content += delta.Delta.Content
+		choice.Message.ReasoningContent += delta.Delta.ReasoningContent
+		if delta.FinishReason != "" {
+			choice.FinishReason = delta.FinishReason
+		}
+
+		for i, call := range delta.Delta.ToolCalls {
+			a.addToolCall(delta.Index, i, call)
+		}
+	}
+}
+
+// Response возвращает ответ, собранный из добавленных фрагментов
+func (a *StreamAccumulator) Response() ChatResponse {
+	return a.resp
+}
+
+// choice возвращает вариант ответа с индексом index, при необходимости создавая его
+func (a *StreamAccumulator) choice(index int) *Choice {
+	for len(a.resp.Choices) <= index {
+		a.resp.Choices = append(a.resp.Choices, Choice{Message: Message{Role: "assistant"}})
+		a.toolCalls = append(a.toolCalls, make(map[int]int))
+	}
+	return &a.resp.Choices[index]
+}
+
+// addToolCall добавляет часть вызова инструмента. Фрагменты без индекса
+// сопоставляются по позиции в дельте
+func (a *StreamAccumulator) addToolCall(choiceIndex, position int, part ToolCall) {
+	index := position
+	if part.Index != nil {
+		index = *part.Index
+	}
+
+	msg := &a.resp.Choices[choiceIndex].Message
+	pos, ok := a.toolCalls[choiceIndex][index]
+	if !ok {
+		pos = len(msg.ToolCalls)
+		a.toolCalls[choiceIndex][index] = pos
+		msg.ToolCalls = append(msg.ToolCalls, ToolCall{})
+	}
+
+	call := &msg.ToolCalls[pos]
+	if part.ID != "" {
+		call.ID = part.ID
+	}
+	if part.Type != "" {
+		call.Type = part.Type
+	}
+	if call.Function.Name == "" {
+		call.Function.Name = part.Function.Name
+	}
+	call.Function.Arguments += part.Function.Arguments
+}
+
+// AccumulateStream собирает фрагменты потока в ChatResponse, как если бы ответ
+// был получен через Chat
+func AccumulateStream(chunks []ChatStreamChunk) ChatResponse {
+	var acc StreamAccumulator
+	for _, chunk := range chunks {
+		acc.Add(chunk)
+	}
+	return acc.Response()
+}
@@ -0,0 +1,122 @@
+package llmclient
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestAccumulateStream(t *testing.T) {
+	zero, one := 0, 1
+	chunks := []ChatStreamChunk{
+		{Model: "gpt-4o", Choices: []StreamChoice{{Delta: Message{Role: "assistant", Content: "Check"}}}},
+		{Choices: []StreamChoice{{Delta: Message{Content: "ing"}}}},
+		{Choices: []StreamChoice{{Delta: Message{ToolCalls: []ToolCall{
+			{Index: &zero, ID: "call_1", Type: "function", Function: FunctionCall{Name: "weather", Arguments: `{"ci`}},
+		}}}}},
+		{Choices: []StreamChoice{{Delta: Message{ToolCalls: []ToolCall{
+			{Index: &one, ID: "call_2", Type: "function", Function: FunctionCall{Name: "time", Arguments: `{}`}},
+			{Index: &zero, Function: FunctionCall{Arguments: `ty":"Oslo"}`}},
+		}}}}},
+		{Choices: []StreamChoice{{FinishReason: "tool_calls"}}},
+		{Usage: &Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}},
+	}
+
+	resp := AccumulateStream(chunks)
+
+	if resp.Model != "gpt-4o" || resp.Usage.TotalTokens != 15 {
+		t.Errorf("Unexpected model or usage: %+v", resp)
+	}
+	if len(resp.Choices) != 1 {
+		t.Fatalf("Expected 1 choice, got %d", len(resp.Choices))
+	}
+
+	choice := resp.Choices[0]
+	if choice.Message.Content != "Checking" || choice.FinishReason != "tool_calls" {
+		t.Errorf("Unexpected choice: %+v", choice)
+	}
+
+	calls := choice.Message.ToolCalls
+	if len(calls) != 2 {
+		t.Fatalf("Expected 2 tool calls, got %+v", calls)
+	}
+	if calls[0].ID != "call_1" || calls[0].Function.Arguments != `{"city":"Oslo"}` {
+		t.Errorf("Unexpected first tool call: %+v", calls[0])
+	}
+	if calls[0].Index != nil {
+		t.Error("Expected stream index to be dropped from accumulated tool call")
+	}
+	if calls[1].Function.Name != "time" {
+		t.Errorf("Unexpected second tool call: %+v", calls[1])
+	}
+
+	data, _ := json.Marshal(calls[0])
+	if string(data) != `{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Oslo\"}"}}` {
+		t.Errorf("Unexpected tool call JSON: %s", data)
+	}
+}
+
+func TestStreamAccumulator_ChatStream(t *testing.T) {
+	server := newStreamServer(t, "Hel", "lo")
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model")
+
+	var acc StreamAccumulator
+	err := client.ChatStream(context.Background(), ChatRequest{
+		Messages: []Message{{Role: "user", Content: "Hello"}},
+	}, func(chunk ChatStreamChunk) error {
+		acc.Add(chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	msg, ok := acc.Response().AssistantMessage()
+	if !ok || msg.Content != "Hello" || msg.Role != "assistant" {
+		t.Errorf("Unexpected assistant message: %+v", msg)
+	}
+}
+
+func TestAccumulateStream_Reasoning(t *testing.T) {
+	lines := []string{
+		`{"choices":[{"index":0,"delta":{"role":"assistant","reasoning_content":"Think"}}]}`,
+		`{"choices":[{"index":0,"delta":{"reasoning":"ing..."}}]}`,
+		`{"choices":[{"index":0,"delta":{"content":"42"},"finish_reason":"stop"}]}`,
+	}
+
+	var acc StreamAccumulator
+	for _, line := range lines {
+		var chunk ChatStreamChunk
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		acc.Add(chunk)
+	}
+
+	msg := acc.Response().Choices[0].Message
+	if msg.ReasoningContent != "Thinking..." || msg.Content != "42" {
+		t.Errorf("Expected separate reasoning and answer, got %+v", msg)
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(data) != `{"role":"assistant","content":"42"}` {
+		t.Errorf("Expected reasoning to be omitted from requests, got %s", data)
+	}
+}
+
+func TestAccumulateStream_InvalidChoiceIndex(t *testing.T) {
+	resp := AccumulateStream([]ChatStreamChunk{
+		{Choices: []StreamChoice{{Index: -1, Delta: Message{Content: "negative"}}}},
+		{Choices: []StreamChoice{{Index: 2000000000, Delta: Message{Content: "huge"}}}},
+		{Choices: []StreamChoice{{Index: 0, Delta: Message{Content: "ok"}, FinishReason: "stop"}}},
+	})
+
+	if len(resp.Choices) != 1 || resp.Choices[0].Message.Content != "ok" {
+		t.Errorf("Expected invalid indexes to be skipped, got %+v", resp.Choices)
+	}
+}
@@ -0,0 +1,98 @@
+package llmclient
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Параметры WithAdaptiveTimeout
+const (
+	// adaptiveTimeoutAlpha - вес нового наблюдения в скользящем среднем (EWMA)
+	adaptiveTimeoutAlpha = 0.2
+	// adaptiveTimeoutMinSamples - число наблюдений модели до первого дедлайна
+	adaptiveTimeoutMinSamples = 3
+	// adaptiveTimeoutMin - минимальный дедлайн вызова
+	adaptiveTimeoutMin = time.Second
+)
+
+// adaptiveTimeout хранит скользящее среднее длительности успешных вызовов по моделям
+type adaptiveTimeout struct {
+	factor float64
+
+	mu      sync.Mutex
+	latency map[string]*latencyAverage
+}
+
+// latencyAverage - экспоненциальное скользящее среднее длительности вызовов модели
+type latencyAverage struct {
+	mean    time.Duration
+	samples int
+}
+
+// newAdaptiveTimeout создает учет длительности вызовов с множителем дедлайна factor
+func newAdaptiveTimeout(factor float64) *adaptiveTimeout {
+	return &adaptiveTimeout{factor: factor, latency: make(map[string]*latencyAverage)}
+}
+
+// observe учитывает длительность вызова модели
+func (a *adaptiveTimeout) observe(model string, d time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	avg, ok := a.latency[model]
+	if !ok {
+		a.latency[model] = &latencyAverage{mean: d, samples: 1}
+		return
+	}
+	avg.mean += time.Duration(adaptiveTimeoutAlpha * float64(d-avg.mean))
+	avg.samples++
+}
+
+// timeout возвращает дедлайн вызова модели: factor средних длительностей, но не
+// меньше adaptiveTimeoutMin. Второе значение равно false, пока наблюдений мало
+func (a *adaptiveTimeout) timeout(model string) (time.Duration, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	avg, ok := a.latency[model]
+	if !ok || avg.samples < adaptiveTimeoutMinSamples {
+		return 0, false
+	}
+	return max(time.Duration(a.factor*float64(avg.mean)), adaptiveTimeoutMin), true
+}
+
+// withAdaptiveDeadline ограничивает вызов модели дедлайном WithAdaptiveTimeout, если
+// у контекста вызывающего callerCtx нет своего дедлайна (ctx может быть отвязан от
+// него WithUsageGracePeriod). Дедлайн действует на весь вызов вместе с повторами и
+// паузами между ними, и в среднем учитывается длительность всего вызова.
+// Возвращаемая функция принимает итоговую ошибку вызова: успешный вызов учитывается
+// в среднем, а срабатывание адаптивного дедлайна учитывается как вызов длительностью
+// в дедлайн, чтобы среднее росло вслед за моделью
+func (c *Client) withAdaptiveDeadline(ctx, callerCtx context.Context, model string) (context.Context, func(error)) {
+	if c.adaptiveTimeout == nil {
+		return ctx, func(error) {}
+	}
+
+	start := time.Now()
+	observe := func(err error) {
+		if err == nil {
+			c.adaptiveTimeout.observe(model, time.Since(start))
+		}
+	}
+
+	timeout, ok := c.adaptiveTimeout.timeout(model)
+	if _, hasDeadline := callerCtx.Deadline(); !ok || hasDeadline {
+		return ctx, observe
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func(err error) {
+		if err != nil && parent.Err() == nil && ctx.Err() == context.DeadlineExceeded {
+			c.adaptiveTimeout.observe(model, timeout)
+		}
+		observe(err)
+		cancel()
+	}
+}
@@ -0,0 +1,109 @@
+package llmclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveTimeout(t *testing.T) {
+	a := newAdaptiveTimeout(3)
+	for i := 0; i < adaptiveTimeoutMinSamples-1; i++ {
+		a.observe("model", 2*time.Second)
+	}
+	if _, ok := a.timeout("model"); ok {
+		t.Error("Expected no timeout before enough samples")
+	}
+
+	a.observe("model", 2*time.Second)
+	if timeout, ok := a.timeout("model"); !ok || timeout != 6*time.Second {
+		t.Errorf("Expected 6s timeout, got %v, %v", timeout, ok)
+	}
+
+	a.observe("model", 12*time.Second)
+	if timeout, _ := a.timeout("model"); timeout != 12*time.Second {
+		t.Errorf("Expected EWMA to move towards slow call, got %v", timeout)
+	}
+
+	for i := 0; i < adaptiveTimeoutMinSamples; i++ {
+		a.observe("fast", time.Millisecond)
+	}
+	if timeout, _ := a.timeout("fast"); timeout != adaptiveTimeoutMin {
+		t.Errorf("Expected minimum timeout, got %v", timeout)
+	}
+}
+
+func TestClient_WithAdaptiveTimeout(t *testing.T) {
+	var delay atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Duration(delay.Load())):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithAdaptiveTimeout(2), WithMaxRetries(0))
+	for i := 0; i < adaptiveTimeoutMinSamples; i++ {
+		if _, err := client.SimpleRequest(context.Background(), "sys", "user"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	delay.Store(int64(3 * time.Second))
+	start := time.Now()
+	_, err := client.SimpleRequest(context.Background(), "sys", "user")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected adaptive deadline, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected call to stop at the minimum timeout, took %v", elapsed)
+	}
+	if mean := client.adaptiveTimeout.latency["model"].mean; mean < adaptiveTimeoutMin/10 {
+		t.Errorf("Expected timed out call to raise the average, got %v", mean)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	delay.Store(int64(1200 * time.Millisecond))
+	if _, err := client.SimpleRequest(ctx, "sys", "user"); err != nil {
+		t.Errorf("Expected caller deadline to take precedence, got %v", err)
+	}
+}
+
+func TestClient_WithAdaptiveTimeout_UsageGrace(t *testing.T) {
+	var delay atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Duration(delay.Load())):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model",
+		WithAdaptiveTimeout(2), WithMaxRetries(0), WithUsageGracePeriod(time.Second))
+	for i := 0; i < adaptiveTimeoutMinSamples; i++ {
+		if _, err := client.SimpleRequest(context.Background(), "sys", "user"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	// Контекст запроса отвязан от вызывающего, но его дедлайн по-прежнему имеет приоритет
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	delay.Store(int64(1200 * time.Millisecond))
+	if _, err := client.SimpleRequest(ctx, "sys", "user"); err != nil {
+		t.Errorf("Expected caller deadline to take precedence, got %v", err)
+	}
+}
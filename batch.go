@@ -0,0 +1,257 @@
+package llmclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Пути и параметры Batch API
+const (
+	filesPath             = "/v1/files"
+	batchesPath           = "/v1/batches"
+	batchCompletionWindow = "24h"
+	batchCustomIDPrefix   = "request-"
+)
+
+// Статусы пакетной обработки
+const (
+	BatchStatusValidating = "validating"
+	BatchStatusInProgress = "in_progress"
+	BatchStatusFinalizing = "finalizing"
+	BatchStatusCompleted  = "completed"
+	BatchStatusFailed     = "failed"
+	BatchStatusExpired    = "expired"
+	BatchStatusCancelled  = "cancelled"
+)
+
+// BatchStatus представляет состояние пакетной обработки
+type BatchStatus struct {
+	ID            string             `json:"id"`
+	Status        string             `json:"status"`
+	InputFileID   string             `json:"input_file_id"`
+	OutputFileID  string             `json:"output_file_id"`
+	ErrorFileID   string             `json:"error_file_id"`
+	RequestCounts BatchRequestCounts `json:"request_counts"`
+}
+
+// BatchRequestCounts представляет счетчики запросов в пакете
+type BatchRequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// batchLine представляет строку входного JSONL файла
+type batchLine struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// batchResult представляет строку выходного JSONL файла
+type batchResult struct {
+	CustomID string `json:"custom_id"`
+	Response *struct {
+		StatusCode int          `json:"status_code"`
+		Body       ChatResponse `json:"body"`
+	} `json:"response"`
+}
+
+// CreateBatch загружает запросы в виде JSONL файла и запускает их пакетную обработку.
+// Каждый запрос проходит те же фильтры и проверки, что и в Chat (WithOutboundFilter,
+// сообщения инструментов, WithMaxRequestBytes). Возвращает идентификатор пакета для GetBatch
+func (c *Client) CreateBatch(ctx context.Context, requests []ChatRequest) (string, error) {
+	if len(requests) == 0 {
+		return "", fmt.Errorf("no requests in batch")
+	}
+
+	var input bytes.Buffer
+	encoder := json.NewEncoder(&input)
+	for i, req := range requests {
+		body, err := c.encodeBatchRequest(ctx, req)
+		if err != nil {
+			return "", fmt.Errorf("request %d: %w", i, err)
+		}
+		line := batchLine{
+			CustomID: batchCustomIDPrefix + strconv.Itoa(i),
+			Method:   http.MethodPost,
+			URL:      chatCompletionsPath,
+			Body:     body,
+		}
+		if err := encoder.Encode(line); err != nil {
+			return "", fmt.Errorf("failed to marshal request %d: %w", i, err)
+		}
+	}
+
+	fileID, err := c.uploadBatchFile(ctx, input.Bytes())
+	if err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"input_file_id":     fileID,
+		"endpoint":          chatCompletionsPath,
+		"completion_window": batchCompletionWindow,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	var status BatchStatus
+	if err := c.doJSON(ctx, http.MethodPost, batchesPath, "application/json", bytes.NewReader(body), &status); err != nil {
+		return "", err
+	}
+
+	return status.ID, nil
+}
+
+// encodeBatchRequest подготавливает и кодирует запрос строки пакета так же, как chat
+func (c *Client) encodeBatchRequest(ctx context.Context, req ChatRequest) ([]byte, error) {
+	req, err := c.filterOutbound(c.prepareRequest(ctx, req))
+	if err != nil {
+		return nil, err
+	}
+	if err := validateToolMessages(req.Messages); err != nil {
+		return nil, err
+	}
+	return c.encodeBody(req)
+}
+
+// GetBatch возвращает состояние пакета, а после его завершения - ответы в порядке
+// исходных запросов. Для запросов, завершившихся ошибкой, ответ остается пустым
+func (c *Client) GetBatch(ctx context.Context, batchID string) (BatchStatus, []ChatResponse, error) {
+	var status BatchStatus
+	if err := c.doJSON(ctx, http.MethodGet, batchesPath+"/"+url.PathEscape(batchID), "", nil, &status); err != nil {
+		return status, nil, err
+	}
+
+	if status.Status != BatchStatusCompleted || status.OutputFileID == "" {
+		return status, nil, nil
+	}
+
+	httpReq, err := c.newRequest(ctx, c.defaultEndpoint(), http.MethodGet, filesPath+"/"+url.PathEscape(status.OutputFileID)+"/content", nil)
+	if err != nil {
+		return status, nil, err
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return status, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return status, nil, c.errorParser(resp.StatusCode, body)
+	}
+
+	responses, err := parseBatchResults(resp.Body, status.RequestCounts.Total)
+	return status, responses, err
+}
+
+// uploadBatchFile загружает JSONL файл с назначением "batch" и возвращает его идентификатор
+func (c *Client) uploadBatchFile(ctx context.Context, data []byte) (string, error) {
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+
+	if err := writer.WriteField("purpose", "batch"); err != nil {
+		return "", err
+	}
+	part, err := writer.CreateFormFile("file", "batch.jsonl")
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	var file struct {
+		ID string `json:"id"`
+	}
+	if err := c.doJSON(ctx, http.MethodPost, filesPath, writer.FormDataContentType(), &form, &file); err != nil {
+		return "", fmt.Errorf("failed to upload batch file: %w", err)
+	}
+
+	return file.ID, nil
+}
+
+// doJSON выполняет запрос к API и декодирует JSON ответ в result
+func (c *Client) doJSON(ctx context.Context, method, path, contentType string, body io.Reader, result interface{}) error {
+	return c.doJSONAt(ctx, c.defaultEndpoint(), method, path, contentType, body, result)
+}
+
+// doJSONAt выполняет запрос к эндпоинту ep и декодирует JSON ответ в result
+func (c *Client) doJSONAt(ctx context.Context, ep endpoint, method, path, contentType string, body io.Reader, result interface{}) error {
+	httpReq, err := c.newRequest(ctx, ep, method, path, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return c.errorParser(resp.StatusCode, body)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
+
+// parseBatchResults разбирает выходной JSONL файл и раскладывает ответы
+// по индексам исходных запросов из custom_id. Индекс вне [0, total) - ошибка
+func parseBatchResults(r io.Reader, total int) ([]ChatResponse, error) {
+	responses := make([]ChatResponse, total)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var result batchResult
+		if err := json.Unmarshal(line, &result); err != nil {
+			return nil, fmt.Errorf("failed to decode batch result: %w", err)
+		}
+
+		index, err := strconv.Atoi(strings.TrimPrefix(result.CustomID, batchCustomIDPrefix))
+		if err != nil || index < 0 || index >= total {
+			return nil, fmt.Errorf("unexpected custom_id in batch result: %q", result.CustomID)
+		}
+
+		if result.Response != nil && result.Response.StatusCode == http.StatusOK {
+			responses[index] = result.Response.Body
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read batch results: %w", err)
+	}
+
+	return responses, nil
+}
@@ -0,0 +1,66 @@
+package llmclient
+
+import (
+	"sync"
+	"time"
+)
+
+// circuitBreaker размыкается после threshold подряд неудачных запросов и отклоняет
+// запросы в течение cooldown. Затем пропускает один пробный запрос: успех замыкает
+// предохранитель, неудача снова размыкает его на cooldown
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// newCircuitBreaker создает замкнутый предохранитель
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow проверяет, можно ли выполнить запрос. probe равен true, если запрос
+// пробный, его результат должен быть передан в record
+func (b *circuitBreaker) allow() (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return false, nil
+	}
+
+	now := time.Now()
+	if b.probing || now.Before(b.openUntil) {
+		return false, &CircuitOpenError{RetryAfter: max(b.openUntil.Sub(now), 0)}
+	}
+
+	b.probing = true
+	return true, nil
+}
+
+// record учитывает результат запроса. Ответ сервера замыкает предохранитель,
+// отмененный вызывающим запрос (aborted) не считается ни успехом, ни неудачей.
+// Истечение дедлайна без ответа считается неудачей
+func (b *circuitBreaker) record(probe, responded, aborted bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probing = false
+	}
+
+	switch {
+	case responded:
+		b.failures = 0
+	case aborted:
+	default:
+		b.failures++
+		if probe || b.failures == b.threshold {
+			b.openUntil = time.Now().Add(b.cooldown)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package llmclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_WithCircuitBreaker(t *testing.T) {
+	var hits, healthy atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if healthy.Load() == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model",
+		WithMaxRetries(0),
+		WithCircuitBreaker(2, 50*time.Millisecond),
+	)
+	req := ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Chat(context.Background(), req); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("Expected upstream error on call %d, got %v", i, err)
+		}
+	}
+
+	_, err := client.Chat(context.Background(), req)
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) || !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if openErr.RetryAfter <= 0 || openErr.RetryAfter > 50*time.Millisecond {
+		t.Errorf("Unexpected RetryAfter %s", openErr.RetryAfter)
+	}
+	if hits.Load() != 2 {
+		t.Errorf("Expected open breaker to skip the server, got %d hits", hits.Load())
+	}
+
+	// Неудачный пробный запрос снова размыкает предохранитель
+	time.Sleep(60 * time.Millisecond)
+	if _, err := client.Chat(context.Background(), req); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected failed probe, got %v", err)
+	}
+	if _, err := client.Chat(context.Background(), req); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected breaker to reopen after failed probe, got %v", err)
+	}
+
+	// Успешный пробный запрос замыкает предохранитель
+	healthy.Store(1)
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if _, err := client.Chat(context.Background(), req); err != nil {
+			t.Fatalf("Expected closed breaker on call %d, got %v", i, err)
+		}
+	}
+	if hits.Load() != 6 {
+		t.Errorf("Expected 6 hits, got %d", hits.Load())
+	}
+}
+
+func TestClient_WithCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"message":"bad request"}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithCircuitBreaker(1, time.Minute))
+	req := ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}}
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.Chat(context.Background(), req); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("Expected API error on call %d, got %v", i, err)
+		}
+	}
+}
+
+func TestClient_WithCircuitBreakerCountsDeadlines(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewClient(server.URL, "test-key", "model", WithMaxRetries(0), WithCircuitBreaker(1, time.Minute))
+	req := ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}}
+
+	// Отмена вызывающим не размыкает предохранитель
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := client.Chat(ctx, req); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.Chat(ctx, req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if _, err := client.Chat(context.Background(), req); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected deadline to open the breaker, got %v", err)
+	}
+}
+
+func TestClient_WithCircuitBreakerIgnoresHandlerRetries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model",
+		WithMaxRetries(1),
+		WithBackoff(time.Millisecond, time.Millisecond),
+		WithRetryOnEmptyChoices(true),
+		WithCircuitBreaker(1, time.Minute),
+	)
+	req := ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}}
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.Chat(context.Background(), req); !errors.Is(err, ErrNoChoices) {
+			t.Fatalf("Expected ErrNoChoices on call %d, got %v", i, err)
+		}
+	}
+}
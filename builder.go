@@ -0,0 +1,168 @@
+package llmclient
+
+import "fmt"
+
+// RequestBuilder пошагово собирает ChatRequest и проверяет параметры по ходу сборки.
+// Первая ошибка сохраняется и возвращается из Build, последующие вызовы ее не перезаписывают
+type RequestBuilder struct {
+	req ChatRequest
+	err error
+}
+
+// NewRequest создает построитель запроса для модели (пустая строка - модель клиента)
+func NewRequest(model string) *RequestBuilder {
+	return &RequestBuilder{req: ChatRequest{Model: model}}
+}
+
+// Temperature задает температуру генерации (0.0-2.0)
+func (b *RequestBuilder) Temperature(temperature float32) *RequestBuilder {
+	if temperature < 0 || temperature > 2 {
+		return b.fail("temperature must be between 0 and 2, got %v", temperature)
+	}
+	b.req.Temperature = temperature
+	return b
+}
+
+// TopP задает top-p сэмплирование (0.0-1.0)
+func (b *RequestBuilder) TopP(topP float32) *RequestBuilder {
+	if topP < 0 || topP > 1 {
+		return b.fail("top_p must be between 0 and 1, got %v", topP)
+	}
+	b.req.TopP = topP
+	return b
+}
+
+// MaxTokens задает max_tokens. Несовместим с MaxCompletionTokens
+func (b *RequestBuilder) MaxTokens(n int) *RequestBuilder {
+	if n <= 0 {
+		return b.fail("max_tokens must be positive, got %d", n)
+	}
+	if b.req.MaxCompletionTokens != 0 {
+		return b.fail("max_tokens and max_completion_tokens are mutually exclusive")
+	}
+	b.req.MaxTokens = n
+	return b
+}
+
+// MaxCompletionTokens задает max_completion_tokens. Несовместим с MaxTokens
+func (b *RequestBuilder) MaxCompletionTokens(n int) *RequestBuilder {
+	if n <= 0 {
+		return b.fail("max_completion_tokens must be positive, got %d", n)
+	}
+	if b.req.MaxTokens != 0 {
+		return b.fail("max_tokens and max_completion_tokens are mutually exclusive")
+	}
+	b.req.MaxCompletionTokens = n
+	return b
+}
+
+// Stop задает стоп-последовательности (не более 4)
+func (b *RequestBuilder) Stop(stops ...string) *RequestBuilder {
+	if len(stops) > maxStopSequences {
+		return b.fail("at most %d stop sequences are allowed, got %d", maxStopSequences, len(stops))
+	}
+	b.req.Stop = stops
+	return b
+}
+
+// N задает количество вариантов ответа
+func (b *RequestBuilder) N(n int) *RequestBuilder {
+	if n <= 0 {
+		return b.fail("n must be positive, got %d", n)
+	}
+	b.req.N = n
+	return b
+}
+
+// Seed задает seed для воспроизводимой генерации
+func (b *RequestBuilder) Seed(seed int) *RequestBuilder {
+	b.req.Seed = &seed
+	return b
+}
+
+// ReasoningEffort задает reasoning_effort (low, medium, high)
+func (b *RequestBuilder) ReasoningEffort(effort string) *RequestBuilder {
+	switch effort {
+	case ReasoningEffortLow, ReasoningEffortMedium, ReasoningEffortHigh:
+		b.req.ReasoningEffort = effort
+		return b
+	default:
+		return b.fail("unknown reasoning effort %q", effort)
+	}
+}
+
+// ServiceTier задает service_tier
+func (b *RequestBuilder) ServiceTier(tier string) *RequestBuilder {
+	b.req.ServiceTier = tier
+	return b
+}
+
+// AddMessage добавляет произвольное сообщение
+func (b *RequestBuilder) AddMessage(msg Message) *RequestBuilder {
+	if msg.Role == "" {
+		return b.fail("message %d has no role", len(b.req.Messages))
+	}
+	b.req.Messages = append(b.req.Messages, msg)
+	return b
+}
+
+// AddSystem добавляет системное сообщение
+func (b *RequestBuilder) AddSystem(text string) *RequestBuilder {
+	return b.AddMessage(Message{Role: "system", Content: text})
+}
+
+// AddUser добавляет сообщение пользователя
+func (b *RequestBuilder) AddUser(text string) *RequestBuilder {
+	return b.AddMessage(Message{Role: "user", Content: text})
+}
+
+// AddAssistant добавляет сообщение ассистента
+func (b *RequestBuilder) AddAssistant(text string) *RequestBuilder {
+	return b.AddMessage(Message{Role: "assistant", Content: text})
+}
+
+// AddTool добавляет инструмент. Имена инструментов должны быть уникальными
+func (b *RequestBuilder) AddTool(tool Tool) *RequestBuilder {
+	if tool.Function.Name == "" {
+		return b.fail("tool has no function name")
+	}
+	for _, existing := range b.req.Tools {
+		if existing.Function.Name == tool.Function.Name {
+			return b.fail("duplicate tool %q", tool.Function.Name)
+		}
+	}
+	if tool.Type == "" {
+		tool.Type = "function"
+	}
+	b.req.Tools = append(b.req.Tools, tool)
+	return b
+}
+
+// JSONSchema задает схему структурированного вывода, построенную по instance
+func (b *RequestBuilder) JSONSchema(instance interface{}) *RequestBuilder {
+	schema, err := GenerateSchema(instance)
+	if err != nil {
+		return b.fail("failed to generate schema: %v", err)
+	}
+	b.req.JSONSchema = schema
+	return b
+}
+
+// Build возвращает собранный запрос или первую ошибку сборки
+func (b *RequestBuilder) Build() (ChatRequest, error) {
+	if b.err != nil {
+		return ChatRequest{}, b.err
+	}
+	if len(b.req.Messages) == 0 {
+		return ChatRequest{}, fmt.Errorf("invalid request: no messages")
+	}
+	return b.req, nil
+}
+
+// fail сохраняет первую ошибку сборки
+func (b *RequestBuilder) fail(format string, args ...interface{}) *RequestBuilder {
+	if b.err == nil {
+		b.err = fmt.Errorf("invalid request: "+format, args...)
+	}
+	return b
+}
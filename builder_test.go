@@ -0,0 +1,64 @@
+package llmclient
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRequestBuilder(t *testing.T) {
+	type reply struct {
+		Answer string `json:"answer"`
+	}
+
+	req, err := NewRequest("gpt-4o").
+		Temperature(0.2).
+		MaxTokens(500).
+		Stop("</answer>").
+		AddSystem("Be brief").
+		AddUser("Hello").
+		AddTool(Tool{Function: FunctionDefinition{Name: "lookup"}}).
+		JSONSchema(reply{}).
+		Build()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if req.Model != "gpt-4o" || req.Temperature != 0.2 || req.MaxTokens != 500 {
+		t.Errorf("Unexpected parameters: %+v", req)
+	}
+	if len(req.Messages) != 2 || req.Messages[1].Role != "user" {
+		t.Errorf("Unexpected messages: %+v", req.Messages)
+	}
+	if len(req.Tools) != 1 || req.Tools[0].Type != "function" {
+		t.Errorf("Expected tool type to default to 'function', got %+v", req.Tools)
+	}
+	if req.JSONSchema["title"] != "reply" {
+		t.Errorf("Expected schema to be generated, got %v", req.JSONSchema)
+	}
+}
+
+func TestRequestBuilder_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *RequestBuilder
+		errPart string
+	}{
+		{"both max tokens", NewRequest("o1").AddUser("Hi").MaxTokens(10).MaxCompletionTokens(10), "mutually exclusive"},
+		{"temperature out of range", NewRequest("gpt-4o").AddUser("Hi").Temperature(5), "temperature"},
+		{"too many stops", NewRequest("gpt-4o").AddUser("Hi").Stop("a", "b", "c", "d", "e"), "stop sequences"},
+		{"duplicate tool", NewRequest("gpt-4o").AddUser("Hi").
+			AddTool(Tool{Function: FunctionDefinition{Name: "f"}}).
+			AddTool(Tool{Function: FunctionDefinition{Name: "f"}}), "duplicate tool"},
+		{"no messages", NewRequest("gpt-4o"), "no messages"},
+		{"first error wins", NewRequest("gpt-4o").Temperature(-1).TopP(2), "temperature"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.builder.Build()
+			if err == nil || !strings.Contains(err.Error(), tt.errPart) {
+				t.Errorf("Expected error containing %q, got %v", tt.errPart, err)
+			}
+		})
+	}
+}
@@ -4,12 +4,20 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"reflect"
+	"sort"
 	"time"
 )
 
+// chatCompletionsPath - путь эндпоинта чат-комплишенов относительно baseURL
+const chatCompletionsPath = "/v1/chat/completions"
+
 // Client представляет клиент для взаимодействия с LLM API
 type Client struct {
 	baseURL    string
@@ -17,6 +25,101 @@ type Client struct {
 	model      string
 	httpClient *http.Client
 	maxRetries int
+
+	errorParser         ErrorResponseParser
+	singleSystemMessage bool
+	defaultStop         []string
+	defaultStopAlways   bool
+	retryOnEmptyChoices bool
+	maxTokensField      string
+
+	defaultReasoningEffort string
+	defaultPromptCacheKey  string
+	defaultServiceTier     string
+	defaultTopK            *int
+	defaultTemperature     *float32
+	defaultTopP            *float32
+	defaultSeed            *int
+
+	debugDump        io.Writer
+	bodyLogWriter    io.Writer
+	bodyLogRate      float64
+	transientMarkers []string
+
+	transportMods     []func(*http.Transport)
+	transportWrappers []func(http.RoundTripper) http.RoundTripper
+
+	encodeRequest  RequestEncoder
+	contentType    string
+	decodeResponse ResponseDecoder
+
+	retryLogger        RetryLogger
+	streamReconnects   int
+	streamTotalTimeout time.Duration
+
+	modelRoutes       []modelRoute
+	trimStopSequences bool
+	maxRequestBytes   int64
+
+	strictFinishReason bool
+	timeoutPerToken    time.Duration
+	modelFallbacks     []string
+
+	authHeader string
+	authScheme string
+
+	queryParams    url.Values
+	deadlineHeader string
+
+	redactedHeaders map[string]bool
+	outboundFilter  OutboundFilter
+	httpTrace       *httptrace.ClientTrace
+	locale          string
+	flights         *flightGroup
+	modelDefaults   map[string]ChatRequestDefaults
+	chatPathFunc    func(model string) string
+	useJSONNumber   bool
+
+	afterResponse func(*ChatResponse) error
+	modelLimiter  *modelLimiter
+
+	backoffStrategy BackoffStrategy
+	backoffBase     time.Duration
+	backoffMax      time.Duration
+
+	structuredOutputMode  StructuredOutputMode
+	breaker               *circuitBreaker
+	minAvgLogprob         *float64
+	usageExtractor        func(raw json.RawMessage) (Usage, bool)
+	maxToolRounds         int
+	toolArgumentsFeedback bool
+	globalHeaders         http.Header
+	metricsHook           func(RequestMetrics)
+	hostTransports        map[string]http.RoundTripper
+	onRetryExhausted      func(ctx context.Context, req ChatRequest, lastErr error)
+	softErrorDetector     func(*ChatResponse, http.Header) error
+	usageCallback         func(model string, usage Usage)
+	usageGrace            time.Duration
+	providerExtras        map[string]interface{}
+	fenceLanguages        []string
+
+	temperatureClamp       *[2]float32
+	temperatureClampLogger TemperatureClampLogger
+	useCookieJar           bool
+	cookieJar              http.CookieJar
+	headerCaptures         []*headerCapture
+	schemaExampleInjection bool
+	modelCheck             *modelCheck
+	strictResponseDecoding bool
+	scheduler              *fairScheduler
+	parseRetryErrors       bool
+	streamBufferSize       int
+	expectContinue         bool
+	compression            *requestCompression
+	adaptiveTimeout        *adaptiveTimeout
+	maxHistoryMessages     int
+	tokenBudget            int
+	tokenizer              Tokenizer
 }
 
 // NewClient создает новый экземпляр клиента
@@ -27,148 +130,723 @@ func NewClient(baseURL, apiKey, model string, opts ...Option) *Client {
 		model:      model,
 		httpClient: http.DefaultClient,
 		maxRetries: 3,
+
+		backoffBase: defaultBackoffBase,
+
+		errorParser: parseOpenAIError,
+
+		authHeader: "Authorization",
+		authScheme: "Bearer",
+
+		redactedHeaders: make(map[string]bool),
+
+		encodeRequest:  json.Marshal,
+		contentType:    "application/json",
+		decodeResponse: decodeJSON,
+	}
+
+	for _, name := range defaultRedactedHeaders {
+		c.redactedHeaders[name] = true
 	}
 
 	for _, opt := range opts {
 		opt(c)
 	}
 
+	c.redactedHeaders[http.CanonicalHeaderKey(c.authHeader)] = true
+
+	c.configureTransport()
+	c.configureCookieJar()
+
 	return c
 }
 
-// Chat выполняет запрос к API чат-комплишенов
+// Chat выполняет запрос к API чат-комплишенов. С WithModelFallback при ошибке
+// "модель не найдена" запрос повторяется со следующей запасной моделью;
+// модель, обслужившая запрос, возвращается в ChatResponse.Model
 func (c *Client) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	if err := c.checkModel(ctx, req.Model); err != nil {
+		return ChatResponse{}, err
+	}
+
+	ctx, metrics := c.startMetrics(ctx, false)
+
+	resp, err := c.chat(ctx, req)
+
+	for _, fallback := range c.modelFallbacks {
+		if !isModelNotFound(err) {
+			break
+		}
+		req.Model = fallback
+		resp, err = c.chat(ctx, req)
+	}
+
+	metrics.finish(err)
+	return resp, err
+}
+
+// chat выполняет запрос к API чат-комплишенов для одной модели
+func (c *Client) chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
 	var resp ChatResponse
-	var lastErr error
 
-	if req.Model == "" {
-		req.Model = c.model
+	req, err := c.filterOutbound(c.prepareRequest(ctx, req))
+	if err != nil {
+		return resp, err
+	}
+
+	if err := validateToolMessages(req.Messages); err != nil {
+		return resp, err
+	}
+
+	body, err := c.encodeBody(req)
+	if err != nil {
+		return resp, err
+	}
+
+	callerCtx := ctx
+	ctx, responseStarted, stopGrace := c.withUsageGrace(ctx)
+	defer stopGrace()
+
+	ctx, cancel := c.withTokenDeadline(ctx, callerCtx, req)
+	defer cancel()
+	ctx, finishAdaptive := c.withAdaptiveDeadline(ctx, callerCtx, req.Model)
+	defer func() {
+		finishAdaptive(err)
+	}()
+	ctx = withRequestHeaders(ctx, req.Headers)
+
+	send := func() (ChatResponse, error) {
+		var resp ChatResponse
+		err := c.execute(ctx, req.Model, c.chatPath(req.Model), body, func(apiResp *http.Response) error {
+			responseStarted()
+
+			var err error
+			resp, err = c.parseResponse(apiResp, req)
+			if errors.Is(err, ErrNoChoices) && c.retryOnEmptyChoices {
+				return retryable(err)
+			}
+			if errors.Is(err, ErrLowConfidence) {
+				return retryable(err)
+			}
+			return err
+		})
+		if c.onRetryExhausted != nil && errors.Is(err, ErrMaxRetriesExceeded) {
+			c.onRetryExhausted(ctx, req, err)
+		}
+		return resp, err
+	}
+
+	if c.flights != nil && sendsZeroTemperature(body) {
+		resp, err = c.flights.do(ctx, flightKey(ctx, body), send)
+	} else {
+		resp, err = send()
+	}
+
+	// С WithUsageGracePeriod запрос отменяется через отвязанный контекст,
+	// поэтому ошибка дополняется причиной отмены контекста вызывающего
+	if callerErr := callerCtx.Err(); err != nil && callerErr != nil && !errors.Is(err, callerErr) {
+		err = fmt.Errorf("%w: %w", callerErr, err)
+	}
+
+	return resp, err
+}
+
+// execute отправляет запрос на path с повторами и передает первый ответ, который не
+// требует повтора, в handle. Ошибка, обернутая в retryable, расходует попытку.
+// Задержку, указанную сервером в Retry-After, клиент соблюдает вместо своего backoff,
+// ограничивая ее WithBackoff max (по умолчанию минутой). С WithCircuitBreaker неудачей считается вызов,
+// последняя попытка которого не дошла до handle (ошибка транспорта, статус для
+// повтора, истечение дедлайна). Отмена контекста вызывающим не учитывается
+func (c *Client) execute(ctx context.Context, model, path string, body []byte, handle func(*http.Response) error) error {
+	var responded bool
+	if c.breaker != nil {
+		probe, err := c.breaker.allow()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			c.breaker.record(probe, responded, errors.Is(ctx.Err(), context.Canceled))
+		}()
 	}
 
+	var lastErr error
+	var lastStatus int
+	var wait, serverWait time.Duration
+	var hasServerWait bool
+
 	for attempt := 0; attempt <= c.maxRetries; attempt++ {
 		if attempt > 0 {
+			if hasServerWait {
+				limit := c.backoffMax
+				if limit <= 0 {
+					limit = defaultRetryAfterMax
+				}
+				wait = min(serverWait, limit)
+			} else {
+				wait = c.backoff(attempt-1, wait)
+			}
+			if c.retryLogger != nil {
+				c.retryLogger(attempt, lastStatus, lastErr, wait)
+			}
+
 			select {
 			case <-ctx.Done():
-				return resp, ctx.Err()
-			case <-time.After(backoff(attempt - 1)):
+				return ctx.Err()
+			case <-time.After(wait):
 			}
 		}
 
-		apiResp, err := c.doRequest(ctx, req)
+		lastStatus = 0
+		hasServerWait = false
+		responded = false
+		apiResp, err := c.doRequest(ctx, model, path, body)
 		if err != nil {
 			lastErr = err
-			if !shouldRetry(err, nil) {
-				return resp, err
+			if !c.shouldRetry(err, nil) {
+				return err
 			}
 			continue
 		}
 
-		if !shouldRetry(nil, apiResp) {
-			defer apiResp.Body.Close()
-			return parseResponse(apiResp)
+		lastStatus = apiResp.StatusCode
+
+		if !c.shouldRetry(nil, apiResp) {
+			// ответ сервера, отклоненный handle (ErrNoChoices, ErrLowConfidence),
+			// не является отказом сервера
+			responded = true
+			err := func() error {
+				defer apiResp.Body.Close()
+				return handle(apiResp)
+			}()
+
+			var retryErr *retryableError
+			if errors.As(err, &retryErr) {
+				lastErr = retryErr.err
+				continue
+			}
+			return err
 		}
 
+		serverWait, hasServerWait = retryAfter(apiResp, time.Now())
+		if c.parseRetryErrors {
+			errBody, _ := io.ReadAll(apiResp.Body)
+			lastErr = c.errorParser(apiResp.StatusCode, errBody)
+		} else {
+			lastErr = newStatusError(apiResp)
+		}
 		apiResp.Body.Close()
-		lastErr = fmt.Errorf("HTTP %d", apiResp.StatusCode)
 	}
 
-	return resp, fmt.Errorf("max retries exceeded: %w", lastErr)
+	return fmt.Errorf("%w: %w", ErrMaxRetriesExceeded, lastErr)
 }
 
 // SimpleRequest выполняет простой запрос с системным и пользовательским промптом
+// и возвращает первый вариант ответа. Для нескольких вариантов используйте
+// SimpleRequestN или ChatContents
 func (c *Client) SimpleRequest(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
-	messages := make([]Message, 0, 2)
+	req := ChatRequest{
+		Messages: simpleMessages(systemPrompt, userPrompt),
+	}
 
-	if systemPrompt != "" {
-		messages = append(messages, Message{Role: "system", Content: systemPrompt})
+	resp, err := c.Chat(ctx, req)
+	if err != nil {
+		return "", err
 	}
 
-	messages = append(messages, Message{Role: "user", Content: userPrompt})
+	if len(resp.Choices) == 0 {
+		return "", ErrNoChoices
+	}
+
+	return resp.Choices[0].Message.Content, nil
+}
 
+// SimpleRequestN выполняет простой запрос, запрашивая n вариантов ответа (параметр n),
+// и возвращает содержимое всех полученных вариантов. При n <= 1 запрашивается один вариант
+func (c *Client) SimpleRequestN(ctx context.Context, systemPrompt, userPrompt string, n int) ([]string, error) {
 	req := ChatRequest{
-		Messages: messages,
+		Messages: simpleMessages(systemPrompt, userPrompt),
 	}
+	if n > 1 {
+		req.N = n
+	}
+
+	contents, _, err := c.ChatContents(ctx, req)
+	return contents, err
+}
+
+// simpleMessages строит сообщения из системного (может быть пустым)
+// и пользовательского промпта
+func simpleMessages(systemPrompt, userPrompt string) []Message {
+	messages := make([]Message, 0, 2)
+
+	if systemPrompt != "" {
+		messages = append(messages, Message{Role: "system", Content: systemPrompt})
+	}
+
+	return append(messages, Message{Role: "user", Content: userPrompt})
+}
 
+// ChatContents выполняет запрос и возвращает содержимое всех вариантов ответа
+// (например, при N > 1) вместе с информацией об использовании токенов
+func (c *Client) ChatContents(ctx context.Context, req ChatRequest) ([]string, Usage, error) {
 	resp, err := c.Chat(ctx, req)
 	if err != nil {
-		return "", err
+		return nil, resp.Usage, err
 	}
 
 	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("no choices in response")
+		return nil, resp.Usage, ErrNoChoices
 	}
 
-	return resp.Choices[0].Message.Content, nil
+	contents := make([]string, 0, len(resp.Choices))
+	for _, choice := range resp.Choices {
+		contents = append(contents, choice.Message.Content)
+	}
+
+	return contents, resp.Usage, nil
 }
 
-// RequestWithSchema выполняет запрос с промптом и схемой JSON
+// RequestWithSchema выполняет запрос с промптом и схемой JSON и разбирает в schema
+// первый вариант ответа. Для выбора среди нескольких вариантов используйте
+// RequestWithSchemaVote
 func (c *Client) RequestWithSchema(ctx context.Context, systemPrompt, userPrompt string, schema interface{}) error {
 	jsonSchema, err := GenerateSchema(schema)
 	if err != nil {
 		return err
 	}
 
+	req, err := c.applyStructuredOutput(ChatRequest{
+		Messages: []Message{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: userPrompt},
+		},
+	}, jsonSchema)
+	if err != nil {
+		return err
+	}
+
+	return c.requestDecoded(ctx, req, c.unmarshalSchema, []string{"json"}, schema)
+}
+
+// RequestWithDecoder выполняет запрос с промптом и разбирает первый вариант ответа
+// в into функцией decode (например, для XML, YAML или CSV). Перед разбором вокруг
+// ответа удаляется блок кода markdown с меткой языка из WithCodeFenceLanguages
+// (по умолчанию с любой меткой)
+func (c *Client) RequestWithDecoder(ctx context.Context, systemPrompt, userPrompt string, decode func(content string, into interface{}) error, into interface{}) error {
 	req := ChatRequest{
 		Messages: []Message{
 			{Role: "system", Content: systemPrompt},
 			{Role: "user", Content: userPrompt},
 		},
-		JSONSchema: jsonSchema,
 	}
 
+	return c.requestDecoded(ctx, req, decode, c.fenceLanguages, into)
+}
+
+// requestDecoded выполняет запрос и разбирает первый вариант ответа в into,
+// удалив блок кода с меткой одного из languages
+func (c *Client) requestDecoded(ctx context.Context, req ChatRequest, decode func(content string, into interface{}) error, languages []string, into interface{}) error {
 	resp, err := c.Chat(ctx, req)
 	if err != nil {
 		return err
 	}
 
-	// todo - добавить парсинг JSON в ответе
 	if len(resp.Choices) == 0 {
-		return fmt.Errorf("no choices in response")
+		return ErrNoChoices
 	}
 
-	cleanContent := cleanJSONResponse(resp.Choices[0].Message.Content)
+	return decode(StripCodeFence(resp.Choices[0].Message.Content, languages...), into)
+}
 
-	err = json.Unmarshal([]byte(cleanContent), schema)
+// RequestWithSchemaStrict выполняет запрос со схемой JSON, проверяя ответ локально
+// через ValidateAgainstSchema. Если ответ не соответствует схеме, модели отправляется
+// ее ответ и сообщение с ошибкой проверки, и запрос повторяется, всего не более
+// maxAttempts раз. Если ни один ответ не прошел проверку, возвращается последняя
+// ошибка проверки. Используется первый вариант ответа
+func (c *Client) RequestWithSchemaStrict(ctx context.Context, systemPrompt, userPrompt string, schema interface{}, maxAttempts int) error {
+	jsonSchema, err := GenerateSchema(schema)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	req, err := c.applyStructuredOutput(ChatRequest{
+		Messages: []Message{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: userPrompt},
+		},
+	}, jsonSchema)
+	if err != nil {
+		return err
+	}
+
+	maxAttempts = max(maxAttempts, 1)
+
+	var lastErr error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		resp, err := c.Chat(ctx, req)
+		if err != nil {
+			return err
+		}
+		if len(resp.Choices) == 0 {
+			return ErrNoChoices
+		}
+
+		content := resp.Choices[0].Message.Content
+		cleanContent := cleanJSONResponse(content)
+
+		lastErr = ValidateAgainstSchema(jsonSchema, []byte(cleanContent))
+		if lastErr == nil {
+			if lastErr = c.unmarshalSchema(cleanContent, schema); lastErr == nil {
+				return nil
+			}
+		}
+
+		req.Messages = append(req.Messages,
+			Message{Role: "assistant", Content: content},
+			Message{Role: "user", Content: fmt.Sprintf(
+				"The previous response does not match the JSON Schema: %v. Respond again with corrected JSON only.", lastErr)},
+		)
+	}
+
+	return fmt.Errorf("schema validation failed after %d attempts: %w", maxAttempts, lastErr)
 }
 
-// doRequest выполняет HTTP запрос к API
-func (c *Client) doRequest(ctx context.Context, req ChatRequest) (*http.Response, error) {
-	jsonData, err := json.Marshal(req)
+// RequestWithSchemaVote запрашивает n вариантов ответа по схеме JSON и записывает
+// в schema самый частый из них (self-consistency). Варианты сравниваются по
+// каноническому JSON после разбора в тип schema, при равенстве голосов побеждает
+// вариант, встретившийся первым. Варианты, которые не удалось разобрать, не учитываются
+func (c *Client) RequestWithSchemaVote(ctx context.Context, systemPrompt, userPrompt string, schema interface{}, n int) error {
+	target := reflect.TypeOf(schema)
+	if target == nil || target.Kind() != reflect.Ptr {
+		return fmt.Errorf("schema must be a pointer, got %T", schema)
+	}
+
+	jsonSchema, err := GenerateSchema(schema)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return err
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewReader(jsonData))
+	req, err := c.applyStructuredOutput(ChatRequest{
+		Messages: []Message{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: userPrompt},
+		},
+		N: n,
+	}, jsonSchema)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Chat(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	votes := make(map[string]int)
+	var order []string
+	var lastErr error
+
+	for _, choice := range resp.Choices {
+		candidate := reflect.New(target.Elem()).Interface()
+		if err := c.unmarshalSchema(cleanJSONResponse(choice.Message.Content), candidate); err != nil {
+			lastErr = err
+			continue
+		}
+
+		canonical, err := json.Marshal(candidate)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		key := string(canonical)
+		if votes[key] == 0 {
+			order = append(order, key)
+		}
+		votes[key]++
+	}
+
+	if len(order) == 0 {
+		return fmt.Errorf("no valid choices among %d: %w", len(resp.Choices), lastErr)
+	}
+
+	winner := order[0]
+	for _, key := range order[1:] {
+		if votes[key] > votes[winner] {
+			winner = key
+		}
+	}
+
+	return c.unmarshalContent([]byte(winner), schema)
+}
+
+// unmarshalSchema разбирает очищенный ответ модели в структуру схемы v.
+// Ошибка разбора возвращается как *SchemaUnmarshalError
+func (c *Client) unmarshalSchema(content string, v interface{}) error {
+	if err := c.unmarshalContent([]byte(content), v); err != nil {
+		return &SchemaUnmarshalError{Content: content, Err: err}
+	}
+	return nil
+}
+
+// unmarshalContent разбирает JSON из ответа модели в v. С WithUseJSONNumber
+// числа в полях interface{} и map[string]interface{} сохраняются как json.Number
+func (c *Client) unmarshalContent(data []byte, v interface{}) error {
+	if !c.useJSONNumber {
+		return json.Unmarshal(data, v)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return fmt.Errorf("invalid character after top-level value")
+	}
+	return nil
+}
+
+// RequestEncoder сериализует тело запроса
+type RequestEncoder func(v interface{}) ([]byte, error)
+
+// ResponseDecoder десериализует тело ответа в v
+type ResponseDecoder func(r io.Reader, v interface{}) error
+
+// decodeJSON - декодер ответов по умолчанию
+func decodeJSON(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+// newRequest создает HTTP запрос к эндпоинту с заголовком авторизации
+// и параметрами строки запроса из WithQueryParam
+func (c *Client) newRequest(ctx context.Context, ep endpoint, method, path string, body io.Reader) (*http.Request, error) {
+	if c.httpTrace != nil {
+		ctx = httptrace.WithClientTrace(ctx, c.httpTrace)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, ep.baseURL+path, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	httpReq.Header.Set(c.authHeader, c.authValue(ep.apiKey))
+
+	if c.deadlineHeader != "" {
+		setDeadlineHeader(httpReq, c.deadlineHeader)
+	}
 
-	return c.httpClient.Do(httpReq)
+	if len(c.queryParams) > 0 {
+		query := httpReq.URL.Query()
+		for key, values := range c.queryParams {
+			for _, value := range values {
+				query.Add(key, value)
+			}
+		}
+		httpReq.URL.RawQuery = query.Encode()
+	}
+
+	return httpReq, nil
+}
+
+// chatPath возвращает путь эндпоинта чат-комплишенов для модели
+func (c *Client) chatPath(model string) string {
+	if c.chatPathFunc != nil {
+		return c.chatPathFunc(model)
+	}
+	return chatCompletionsPath
 }
 
-// parseResponse парсит HTTP ответ в структуру ChatResponse
-func parseResponse(resp *http.Response) (ChatResponse, error) {
+// authValue возвращает значение заголовка авторизации для ключа
+func (c *Client) authValue(key string) string {
+	if c.authScheme == "" {
+		return key
+	}
+	return c.authScheme + " " + key
+}
+
+// doRequest выполняет POST запрос на path эндпоинта модели с уже сериализованным телом.
+// С WithConcurrencyPerModel слот модели занимается до закрытия тела ответа
+func (c *Client) doRequest(ctx context.Context, model, path string, body []byte) (*http.Response, error) {
+	payload, compressed := c.compressBody(body)
+	httpReq, err := c.newRequest(ctx, c.resolveEndpoint(model), http.MethodPost, path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.Header.Set("Content-Type", c.contentType)
+	if compressed {
+		httpReq.Header.Set("Content-Encoding", "gzip")
+	}
+	if c.expectContinue {
+		httpReq.Header.Set("Expect", "100-continue")
+	}
+	if c.locale != "" {
+		httpReq.Header.Set("Accept-Language", c.locale)
+	}
+
+	// Приоритет заголовков: запрос > контекст > клиент > заголовки по умолчанию
+	for _, headers := range []http.Header{c.globalHeaders, headersFromContext(ctx), requestHeadersFromContext(ctx)} {
+		for name, values := range headers {
+			httpReq.Header[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+		}
+	}
+
+	c.applyCapturedHeaders(httpReq)
+
+	if c.scheduler != nil {
+		if err := c.scheduler.acquire(ctx, model); err != nil {
+			return nil, err
+		}
+	}
+
+	release := func() {}
+	if c.modelLimiter != nil {
+		if release, err = c.modelLimiter.acquire(ctx, model); err != nil {
+			return nil, err
+		}
+	}
+	released := false
+	defer func() {
+		if !released {
+			release()
+		}
+	}()
+
+	metrics := metricsFromContext(ctx)
+	metrics.attempt(model, len(body))
+
+	dumps := c.dumpWriters()
+	for _, w := range dumps {
+		c.dumpRequest(w, httpReq, body)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	// Сервер не принимает сжатые запросы: повторяем без сжатия и больше не сжимаем.
+	// Отклоненная попытка попадает в дампы и метрики как обычный ответ
+	if compressed && resp.StatusCode == http.StatusUnsupportedMediaType {
+		for _, w := range dumps {
+			c.dumpResponse(w, resp)
+		}
+		metrics.response(resp)
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		c.compression.rejected.Store(true)
+		release()
+		released = true
+		return c.doRequest(ctx, model, path, body)
+	}
+
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	released = true
+
+	c.captureResponseHeaders(resp)
+
+	for _, w := range dumps {
+		c.dumpResponse(w, resp)
+	}
+
+	metrics.response(resp)
+
+	return resp, nil
+}
+
+// checkResponseShape проверяет, что успешный ответ - JSON объект с полем choices.
+// Иначе возвращает *ResponseShapeError с полями, которые вернул сервер
+func checkResponseShape(raw []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+		return &ResponseShapeError{Body: string(raw)}
+	}
+	if _, ok := fields["choices"]; ok {
+		return nil
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return &ResponseShapeError{Fields: names, Body: string(raw)}
+}
+
+// parseResponse парсит HTTP ответ на запрос req в структуру ChatResponse
+func (c *Client) parseResponse(resp *http.Response, req ChatRequest) (ChatResponse, error) {
 	var result ChatResponse
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return result, fmt.Errorf("API error: status %d, body: %s", resp.StatusCode, string(body))
+		return result, c.errorParser(resp.StatusCode, body)
+	}
+
+	if isEventStream(resp) {
+		return result, ErrUnexpectedStream
+	}
+
+	if c.usageExtractor == nil && !c.strictResponseDecoding {
+		if err := c.decodeResponse(resp.Body, &result); err != nil {
+			return result, fmt.Errorf("failed to decode response: %w", err)
+		}
+	} else {
+		raw, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return result, fmt.Errorf("failed to read response: %w", err)
+		}
+		if c.strictResponseDecoding {
+			if err := checkResponseShape(raw); err != nil {
+				return result, err
+			}
+		}
+		if err := c.decodeResponse(bytes.NewReader(raw), &result); err != nil {
+			return result, fmt.Errorf("failed to decode response: %w", err)
+		}
+		if c.usageExtractor != nil && result.Usage.isZero() {
+			if usage, ok := c.usageExtractor(raw); ok {
+				result.Usage = usage
+			}
+		}
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return result, fmt.Errorf("failed to decode response: %w", err)
+	c.reportUsage(req, result)
+
+	if c.softErrorDetector != nil {
+		result.SoftError = c.softErrorDetector(&result, resp.Header)
+	}
+
+	if c.afterResponse != nil {
+		if err := c.afterResponse(&result); err != nil {
+			return result, fmt.Errorf("after response hook: %w", err)
+		}
 	}
 
 	if len(result.Choices) == 0 {
-		return result, fmt.Errorf("no choices in response")
+		return result, ErrNoChoices
+	}
+
+	if c.trimStopSequences {
+		for i := range result.Choices {
+			result.Choices[i].Message.Content = trimStopSuffix(result.Choices[i].Message.Content, req.Stop)
+		}
+	}
+
+	if c.strictFinishReason {
+		for i, choice := range result.Choices {
+			if choice.FinishReason != "stop" && (choice.FinishReason != "tool_calls" || len(req.Tools) == 0) {
+				return result, &UnexpectedFinishError{Reason: choice.FinishReason, Index: i}
+			}
+		}
+	}
+
+	if c.minAvgLogprob != nil && req.Logprobs {
+		for i, choice := range result.Choices {
+			if avg, ok := choice.Logprobs.AvgLogprob(); ok && avg < *c.minAvgLogprob {
+				return result, &LowConfidenceError{Index: i, AvgLogprob: avg, Threshold: *c.minAvgLogprob}
+			}
+		}
 	}
 
 	return result, nil
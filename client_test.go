@@ -1,10 +1,21 @@
 package llmclient
 
 import (
+	"bytes"
 	"context"
+	"encoding/gob"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -156,6 +167,31 @@ func TestClient_Chat_MaxRetriesExceeded(t *testing.T) {
 	}
 }
 
+func TestClient_Chat_MaxRetriesExceeded_StatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error":{"message":"upstream unavailable"}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithMaxRetries(1), WithBackoff(time.Millisecond, time.Millisecond))
+	_, err := client.Chat(context.Background(), ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}})
+	if !errors.Is(err, ErrMaxRetriesExceeded) {
+		t.Fatalf("Expected ErrMaxRetriesExceeded, got %v", err)
+	}
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("Expected *StatusError in chain, got %v", err)
+	}
+	if statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", statusErr.StatusCode)
+	}
+	if !strings.Contains(statusErr.Body, "upstream unavailable") {
+		t.Errorf("Expected body snippet, got %s", statusErr.Body)
+	}
+}
+
 func TestClient_Chat_ContextTimeout(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(100 * time.Millisecond)
@@ -241,6 +277,43 @@ func TestClient_CustomMaxRetries(t *testing.T) {
 	}
 }
 
+func TestClient_WithRetryLogger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	type retryRecord struct {
+		attempt int
+		status  int
+		wait    time.Duration
+	}
+	var retries []retryRecord
+
+	client := NewClient(server.URL, "test-key", "model", WithMaxRetries(2),
+		WithBackoff(10*time.Millisecond, time.Second),
+		WithRetryLogger(func(attempt int, status int, err error, wait time.Duration) {
+			if err == nil {
+				t.Error("Expected retry reason to be passed")
+			}
+			retries = append(retries, retryRecord{attempt, status, wait})
+		}))
+
+	if _, err := client.Chat(context.Background(), ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}}); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+
+	expected := []retryRecord{{1, http.StatusInternalServerError, 10 * time.Millisecond}, {2, http.StatusInternalServerError, 20 * time.Millisecond}}
+	if len(retries) != len(expected) {
+		t.Fatalf("Expected %d retry log entries, got %d", len(expected), len(retries))
+	}
+	for i := range expected {
+		if retries[i] != expected[i] {
+			t.Errorf("Retry %d: expected %+v, got %+v", i, expected[i], retries[i])
+		}
+	}
+}
+
 func TestClient_SimpleRequest_WithSystemPrompt(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var req ChatRequest
@@ -338,3 +411,2309 @@ func TestClient_SimpleRequest_WithoutSystemPrompt(t *testing.T) {
 		t.Errorf("Unexpected response content: %s", result)
 	}
 }
+
+func TestClient_Config(t *testing.T) {
+	client := NewClient("https://api.openai.com", "sk-test-1234567890abcd", "gpt-4o", WithMaxRetries(5))
+
+	cfg := client.Config()
+
+	if cfg.BaseURL != "https://api.openai.com" {
+		t.Errorf("Unexpected base URL: %s", cfg.BaseURL)
+	}
+	if cfg.Endpoint != "https://api.openai.com/v1/chat/completions" {
+		t.Errorf("Unexpected endpoint: %s", cfg.Endpoint)
+	}
+	if cfg.Model != "gpt-4o" {
+		t.Errorf("Unexpected model: %s", cfg.Model)
+	}
+	if cfg.MaxRetries != 5 {
+		t.Errorf("Expected max retries to be 5, got %d", cfg.MaxRetries)
+	}
+	if cfg.APIKey != "sk-...abcd" {
+		t.Errorf("Expected API key to be masked, got %s", cfg.APIKey)
+	}
+	if cfg.Headers["Authorization"] != "Bearer sk-...abcd" {
+		t.Errorf("Expected Authorization header to be masked, got %s", cfg.Headers["Authorization"])
+	}
+}
+
+func TestClient_Chat_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","param":null,"code":"invalid_api_key"}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "bad-key", "model")
+	_, err := client.SimpleRequest(context.Background(), "", "Hello")
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected *APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401, got %d", apiErr.StatusCode)
+	}
+	if apiErr.Code != "invalid_api_key" {
+		t.Errorf("Expected code 'invalid_api_key', got %s", apiErr.Code)
+	}
+	if apiErr.Message != "Incorrect API key provided" {
+		t.Errorf("Unexpected message: %s", apiErr.Message)
+	}
+}
+
+func TestClient_WithErrorResponseParser(t *testing.T) {
+	errQuota := errors.New("quota exceeded")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message":"quota exceeded"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithErrorResponseParser(func(status int, body []byte) error {
+		if status == http.StatusForbidden && strings.Contains(string(body), "quota") {
+			return errQuota
+		}
+		return errors.New(string(body))
+	}))
+
+	_, err := client.SimpleRequest(context.Background(), "", "Hello")
+	if !errors.Is(err, errQuota) {
+		t.Errorf("Expected quota error, got %v", err)
+	}
+
+	client = NewClient(server.URL, "test-key", "model", WithErrorResponseParser(nil))
+	_, err = client.SimpleRequest(context.Background(), "", "Hello")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected nil parser to keep the default *APIError, got %v", err)
+	}
+}
+
+func TestClient_WithSingleSystemMessage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+
+		if len(req.Messages) != 3 {
+			t.Fatalf("Expected 3 messages, got %d", len(req.Messages))
+		}
+		if req.Messages[0].Role != "system" || req.Messages[0].Content != "Rule one\nRule two" {
+			t.Errorf("Unexpected collapsed system message: %+v", req.Messages[0])
+		}
+		if req.Messages[2].Role != "system" {
+			t.Errorf("Expected non-leading system message to be preserved, got %+v", req.Messages[2])
+		}
+
+		resp := ChatResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: "ok"}, FinishReason: "stop"}},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithSingleSystemMessage())
+	req := ChatRequest{
+		Messages: []Message{
+			{Role: "system", Content: "Rule one"},
+			{Role: "system", Content: "Rule two"},
+			{Role: "user", Content: "Hello"},
+			{Role: "system", Content: "Late note"},
+		},
+	}
+
+	if _, err := client.Chat(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestClient_WithDefaultStop(t *testing.T) {
+	var gotStop []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		gotStop = req.Stop
+
+		resp := ChatResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: "ok"}, FinishReason: "stop"}},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithDefaultStop("</answer>"))
+	if _, err := client.SimpleRequest(context.Background(), "", "Hello"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(gotStop) != 1 || gotStop[0] != "</answer>" {
+		t.Errorf("Expected default stop to be applied, got %v", gotStop)
+	}
+
+	req := ChatRequest{
+		Messages: []Message{{Role: "user", Content: "Hello"}},
+		Stop:     []string{"a", "b", "</answer>", "c"},
+	}
+	if _, err := client.Chat(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(gotStop) != 4 || gotStop[3] != "c" {
+		t.Errorf("Expected request stops to be kept as is, got %v", gotStop)
+	}
+
+	client = NewClient(server.URL, "test-key", "model", WithDefaultStop("</answer>", "END"), WithDefaultStopAlways(true))
+	req.Stop = []string{"a", "</answer>", "b"}
+	if _, err := client.Chat(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"a", "</answer>", "b", "END"}
+	if strings.Join(gotStop, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected merged stops %v, got %v", expected, gotStop)
+	}
+}
+
+func TestClient_WithRetryOnEmptyChoices(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		resp := ChatResponse{}
+		if attempts > 1 {
+			resp.Choices = []Choice{{Message: Message{Role: "assistant", Content: "ok"}, FinishReason: "stop"}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithMaxRetries(1))
+	if _, err := client.SimpleRequest(context.Background(), "", "Hello"); !errors.Is(err, ErrNoChoices) {
+		t.Fatalf("Expected ErrNoChoices without the option, got %v", err)
+	}
+
+	attempts = 0
+	client = NewClient(server.URL, "test-key", "model", WithMaxRetries(1), WithRetryOnEmptyChoices(true))
+	result, err := client.SimpleRequest(context.Background(), "", "Hello")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+	if result != "ok" {
+		t.Errorf("Unexpected response content: %s", result)
+	}
+}
+
+func TestClient_ChatContents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		if req.N != 2 {
+			t.Errorf("Expected n to be 2, got %d", req.N)
+		}
+
+		resp := ChatResponse{
+			Choices: []Choice{
+				{Message: Message{Role: "assistant", Content: "first"}, FinishReason: "stop"},
+				{Message: Message{Role: "assistant", Content: "second"}, FinishReason: "stop"},
+			},
+			Usage: Usage{PromptTokens: 3, CompletionTokens: 4, TotalTokens: 7},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model")
+	contents, usage, err := client.ChatContents(context.Background(), ChatRequest{
+		Messages: []Message{{Role: "user", Content: "Hello"}},
+		N:        2,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if strings.Join(contents, ",") != "first,second" {
+		t.Errorf("Unexpected contents: %v", contents)
+	}
+	if usage.TotalTokens != 7 {
+		t.Errorf("Expected total tokens to be 7, got %d", usage.TotalTokens)
+	}
+}
+
+func TestClient_SimpleRequestN(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+
+		resp := ChatResponse{
+			Choices: []Choice{
+				{Message: Message{Role: "assistant", Content: "first"}, FinishReason: "stop"},
+				{Message: Message{Role: "assistant", Content: "second"}, FinishReason: "stop"},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model")
+	contents, err := client.SimpleRequestN(context.Background(), "sys", "Hello", 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(contents, ",") != "first,second" {
+		t.Errorf("Unexpected contents: %v", contents)
+	}
+	if body["n"] != float64(2) || len(body["messages"].([]interface{})) != 2 {
+		t.Errorf("Unexpected request: %v", body)
+	}
+
+	if _, err := client.SimpleRequestN(context.Background(), "", "Hello", 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := body["n"]; ok {
+		t.Errorf("Expected n to be omitted for a single choice, got %v", body["n"])
+	}
+
+	result, err := client.SimpleRequest(context.Background(), "", "Hello")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != "first" {
+		t.Errorf("Expected SimpleRequest to return the first choice, got %q", result)
+	}
+}
+
+func TestClient_MaxTokensField(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+
+		resp := ChatResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: "ok"}, FinishReason: "stop"}},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		model    string
+		opts     []Option
+		req      ChatRequest
+		field    string
+		excluded string
+	}{
+		{"regular model", "gpt-4o", nil, ChatRequest{MaxTokens: 100}, "max_tokens", "max_completion_tokens"},
+		{"reasoning model", "o1-mini", nil, ChatRequest{MaxTokens: 100}, "max_completion_tokens", "max_tokens"},
+		{"prefixed reasoning model", "openai/o3-mini", nil, ChatRequest{MaxTokens: 100}, "max_completion_tokens", "max_tokens"},
+		{"both fields set", "gpt-4o", nil, ChatRequest{MaxTokens: 50, MaxCompletionTokens: 100}, "max_completion_tokens", "max_tokens"},
+		{"explicit field name", "o1-mini", []Option{WithMaxTokensFieldName(MaxTokensField)}, ChatRequest{MaxCompletionTokens: 100}, "max_tokens", "max_completion_tokens"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(server.URL, "test-key", tt.model, tt.opts...)
+			tt.req.Messages = []Message{{Role: "user", Content: "Hello"}}
+
+			if _, err := client.Chat(context.Background(), tt.req); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if body[tt.field] != float64(100) {
+				t.Errorf("Expected %s to be 100, got %v", tt.field, body[tt.field])
+			}
+			if _, ok := body[tt.excluded]; ok {
+				t.Errorf("Expected %s to be omitted", tt.excluded)
+			}
+		})
+	}
+}
+
+func TestClient_WithDefaultReasoningEffort(t *testing.T) {
+	var gotEffort string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		gotEffort = req.ReasoningEffort
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],
+			"usage":{"prompt_tokens":5,"completion_tokens":40,"total_tokens":45,"completion_tokens_details":{"reasoning_tokens":32}}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "o3-mini", WithDefaultReasoningEffort(ReasoningEffortHigh))
+	resp, err := client.Chat(context.Background(), ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if gotEffort != ReasoningEffortHigh {
+		t.Errorf("Expected reasoning effort to be 'high', got %s", gotEffort)
+	}
+	if resp.Usage.CompletionTokensDetails == nil || resp.Usage.CompletionTokensDetails.ReasoningTokens != 32 {
+		t.Errorf("Expected 32 reasoning tokens, got %+v", resp.Usage.CompletionTokensDetails)
+	}
+
+	_, err = client.Chat(context.Background(), ChatRequest{
+		Messages:        []Message{{Role: "user", Content: "Hello"}},
+		ReasoningEffort: ReasoningEffortLow,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if gotEffort != ReasoningEffortLow {
+		t.Errorf("Expected request reasoning effort to win, got %s", gotEffort)
+	}
+}
+
+func TestClient_Chat_UsageDetails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],
+			"usage":{"prompt_tokens":2000,"completion_tokens":10,"total_tokens":2010,
+			"prompt_tokens_details":{"cached_tokens":1920,"audio_tokens":0},
+			"completion_tokens_details":{"reasoning_tokens":0,"accepted_prediction_tokens":4}}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model")
+	resp, err := client.Chat(context.Background(), ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if resp.Usage.PromptTokensDetails == nil || resp.Usage.PromptTokensDetails.CachedTokens != 1920 {
+		t.Errorf("Expected 1920 cached tokens, got %+v", resp.Usage.PromptTokensDetails)
+	}
+	if resp.Usage.CompletionTokensDetails == nil || resp.Usage.CompletionTokensDetails.AcceptedPredictionTokens != 4 {
+		t.Errorf("Expected 4 accepted prediction tokens, got %+v", resp.Usage.CompletionTokensDetails)
+	}
+}
+
+func TestClient_WithDebugDump(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"dumped"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	var dump strings.Builder
+	client := NewClient(server.URL, "secret-key", "model", WithDebugDump(&dump))
+	result, err := client.SimpleRequest(context.Background(), "", "Hello")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != "dumped" {
+		t.Errorf("Expected response to be decoded, got %s", result)
+	}
+
+	out := dump.String()
+	if strings.Contains(out, "secret-key") {
+		t.Error("Expected API key to be redacted from dump")
+	}
+	if !strings.Contains(out, `"content":"Hello"`) {
+		t.Error("Expected request body in dump")
+	}
+	if !strings.Contains(out, `"content":"dumped"`) {
+		t.Error("Expected raw response body in dump")
+	}
+}
+
+func TestClient_WithTransientBodyMarkers(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Content-Type", "application/json")
+		if attempts == 1 {
+			w.Write([]byte(`{"error":{"type":"overloaded_error","message":"Overloaded"}}`))
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Please try again later"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithMaxRetries(1), WithTransientBodyMarkers())
+	result, err := client.SimpleRequest(context.Background(), "", "Hello")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+	if result != "Please try again later" {
+		t.Errorf("Expected peeked body to be decoded in full, got %s", result)
+	}
+}
+
+func TestClient_ContextWithSeed(t *testing.T) {
+	var gotSeed *int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		gotSeed = req.Seed
+
+		resp := ChatResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: "ok"}, FinishReason: "stop"}},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model")
+
+	if _, err := client.SimpleRequest(context.Background(), "", "Hello"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if gotSeed != nil {
+		t.Errorf("Expected seed to be omitted, got %d", *gotSeed)
+	}
+
+	ctx := ContextWithSeed(context.Background(), 42)
+	if _, err := client.SimpleRequest(ctx, "", "Hello"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if gotSeed == nil || *gotSeed != 42 {
+		t.Errorf("Expected seed 42 from context, got %v", gotSeed)
+	}
+
+	seed := 7
+	req := ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}, Seed: &seed}
+	if _, err := client.Chat(ctx, req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if gotSeed == nil || *gotSeed != 7 {
+		t.Errorf("Expected explicit seed 7 to win, got %v", gotSeed)
+	}
+}
+
+func TestClient_Batch(t *testing.T) {
+	var uploaded string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/files", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("purpose") != "batch" {
+			t.Errorf("Expected purpose to be 'batch', got %s", r.FormValue("purpose"))
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("Failed to read uploaded file: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		uploaded = string(data)
+		w.Write([]byte(`{"id":"file-in"}`))
+	})
+	mux.HandleFunc("/v1/batches", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["input_file_id"] != "file-in" || body["endpoint"] != "/v1/chat/completions" {
+			t.Errorf("Unexpected batch body: %v", body)
+		}
+		w.Write([]byte(`{"id":"batch-1","status":"validating"}`))
+	})
+	mux.HandleFunc("/v1/batches/batch-1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"batch-1","status":"completed","output_file_id":"file-out","request_counts":{"total":2,"completed":2,"failed":0}}`))
+	})
+	mux.HandleFunc("/v1/files/file-out/content", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"custom_id":"request-1","response":{"status_code":200,"body":{"choices":[{"message":{"role":"assistant","content":"second"}}]}}}
+{"custom_id":"request-0","response":{"status_code":200,"body":{"choices":[{"message":{"role":"assistant","content":"first"}}]}}}
+`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model")
+	batchID, err := client.CreateBatch(context.Background(), []ChatRequest{
+		{Messages: []Message{{Role: "user", Content: "one"}}},
+		{Messages: []Message{{Role: "user", Content: "two"}}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if batchID != "batch-1" {
+		t.Errorf("Expected batch id 'batch-1', got %s", batchID)
+	}
+	if !strings.Contains(uploaded, `"custom_id":"request-0"`) || !strings.Contains(uploaded, `"model":"model"`) {
+		t.Errorf("Unexpected uploaded JSONL: %s", uploaded)
+	}
+
+	status, responses, err := client.GetBatch(context.Background(), batchID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if status.Status != BatchStatusCompleted {
+		t.Errorf("Expected completed status, got %s", status.Status)
+	}
+	if len(responses) != 2 || responses[0].Choices[0].Message.Content != "first" || responses[1].Choices[0].Message.Content != "second" {
+		t.Errorf("Unexpected batch responses: %+v", responses)
+	}
+}
+
+func TestClient_CreateBatch_OutboundFilter(t *testing.T) {
+	var uploaded string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/files", func(w http.ResponseWriter, r *http.Request) {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("Failed to read uploaded file: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		uploaded = string(data)
+		w.Write([]byte(`{"id":"file-in"}`))
+	})
+	mux.HandleFunc("/v1/batches", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"batch-1","status":"validating"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithOutboundFilter(func(messages []Message) ([]Message, error) {
+		for i := range messages {
+			if strings.Contains(messages[i].Content, "reject") {
+				return nil, errors.New("rejected by filter")
+			}
+			messages[i].Content = strings.ReplaceAll(messages[i].Content, "secret", "[masked]")
+		}
+		return messages, nil
+	}))
+
+	if _, err := client.CreateBatch(context.Background(), []ChatRequest{
+		{Messages: []Message{{Role: "user", Content: "my secret"}}},
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Contains(uploaded, "secret") || !strings.Contains(uploaded, "my [masked]") {
+		t.Errorf("Expected filter to apply to batch lines, got %s", uploaded)
+	}
+
+	uploaded = ""
+	_, err := client.CreateBatch(context.Background(), []ChatRequest{
+		{Messages: []Message{{Role: "user", Content: "ok"}}},
+		{Messages: []Message{{Role: "user", Content: "reject"}}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "request 1") {
+		t.Errorf("Expected filter error for request 1, got %v", err)
+	}
+	if uploaded != "" {
+		t.Errorf("Expected nothing to be uploaded, got %s", uploaded)
+	}
+
+	client = NewClient(server.URL, "test-key", "model", WithMaxRequestBytes(10))
+	var tooLarge *RequestTooLargeError
+	if _, err := client.CreateBatch(context.Background(), []ChatRequest{
+		{Messages: []Message{{Role: "user", Content: "Hello"}}},
+	}); !errors.As(err, &tooLarge) {
+		t.Errorf("Expected *RequestTooLargeError, got %v", err)
+	}
+}
+
+func TestClient_GetBatch_EscapesIDs(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		if strings.HasPrefix(r.URL.Path, "/v1/batches/") {
+			w.Write([]byte(`{"id":"batch/1","status":"completed","output_file_id":"file?out","request_counts":{"total":0}}`))
+			return
+		}
+		w.Write(nil)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model")
+	if _, _, err := client.GetBatch(context.Background(), "batch/1"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(paths, " ") != "/v1/batches/batch%2F1 /v1/files/file%3Fout/content" {
+		t.Errorf("Expected escaped IDs in paths, got %v", paths)
+	}
+}
+
+func TestParseBatchResults_CustomIDOutOfRange(t *testing.T) {
+	for _, id := range []string{"request-2", "request-2000000000", "request--1"} {
+		line := `{"custom_id":"` + id + `","response":{"status_code":200,"body":{"choices":[]}}}`
+		if _, err := parseBatchResults(strings.NewReader(line), 2); err == nil {
+			t.Errorf("Expected error for custom_id %q", id)
+		}
+	}
+}
+
+func TestClient_ForceHTTPVersion(t *testing.T) {
+	var gotProto string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotProto = r.Proto
+		resp := ChatResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: "ok"}, FinishReason: "stop"}},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithHttpClient(server.Client()), WithForceHTTP1())
+	if _, err := client.SimpleRequest(context.Background(), "", "Hello"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if gotProto != "HTTP/1.1" {
+		t.Errorf("Expected HTTP/1.1, got %s", gotProto)
+	}
+
+	client = NewClient(server.URL, "test-key", "model", WithHttpClient(server.Client()), WithForceHTTP2())
+	if _, err := client.SimpleRequest(context.Background(), "", "Hello"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if gotProto != "HTTP/2.0" {
+		t.Errorf("Expected HTTP/2.0, got %s", gotProto)
+	}
+
+	if client.httpClient.Transport == server.Client().Transport {
+		t.Error("Expected the client to use a cloned transport")
+	}
+}
+
+func TestClient_WithExpectContinue(t *testing.T) {
+	var expect string
+	var bodyRead bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expect = r.Header.Get("Expect")
+		if r.ContentLength > 1024 {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		bodyRead = true
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithExpectContinue(250*time.Millisecond))
+	defer client.httpClient.CloseIdleConnections()
+	if transport, ok := client.httpClient.Transport.(*http.Transport); !ok || transport.ExpectContinueTimeout != 250*time.Millisecond {
+		t.Fatalf("Expected ExpectContinueTimeout on a cloned transport, got %#v", client.httpClient.Transport)
+	}
+	if http.DefaultTransport.(*http.Transport).ExpectContinueTimeout == 250*time.Millisecond {
+		t.Error("Expected http.DefaultTransport to be left unchanged")
+	}
+
+	if _, err := client.SimpleRequest(context.Background(), "", "Hello"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expect != "100-continue" || !bodyRead {
+		t.Errorf("Expected Expect header and body upload, got %q, %v", expect, bodyRead)
+	}
+
+	bodyRead = false
+	_, err := client.SimpleRequest(context.Background(), "", strings.Repeat("x", 4096))
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusRequestEntityTooLarge || bodyRead {
+		t.Errorf("Expected early 413 rejection, got %v", err)
+	}
+}
+
+func TestClient_WithCustomCodec(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-gob" {
+			t.Errorf("Expected Content-Type to follow the codec, got %s", r.Header.Get("Content-Type"))
+		}
+
+		var req ChatRequest
+		if err := gob.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+
+		resp := ChatResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: "echo: " + req.Messages[0].Content}, FinishReason: "stop"}},
+		}
+		gob.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model",
+		WithRequestEncoder("application/x-gob", func(v interface{}) ([]byte, error) {
+			var buf bytes.Buffer
+			err := gob.NewEncoder(&buf).Encode(v)
+			return buf.Bytes(), err
+		}),
+		WithResponseDecoder(func(r io.Reader, v interface{}) error {
+			return gob.NewDecoder(r).Decode(v)
+		}),
+	)
+
+	result, err := client.SimpleRequest(context.Background(), "", "Hello")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != "echo: Hello" {
+		t.Errorf("Unexpected response content: %s", result)
+	}
+}
+
+func TestChatResponse_AssistantMessage(t *testing.T) {
+	var resp ChatResponse
+	if _, ok := resp.AssistantMessage(); ok {
+		t.Error("Expected false for response without choices")
+	}
+
+	err := json.Unmarshal([]byte(`{"choices":[{"message":{"role":"assistant","content":"",
+		"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]}}]}`), &resp)
+	if err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	msg, ok := resp.AssistantMessage()
+	if !ok {
+		t.Fatal("Expected assistant message")
+	}
+	if msg.Role != "assistant" || len(msg.ToolCalls) != 1 {
+		t.Fatalf("Unexpected message: %+v", msg)
+	}
+	if msg.ToolCalls[0].ID != "call_1" || msg.ToolCalls[0].Function.Name != "get_weather" {
+		t.Errorf("Unexpected tool call: %+v", msg.ToolCalls[0])
+	}
+}
+
+func TestClient_WithModelPrefix(t *testing.T) {
+	newServer := func(name string, gotKey *string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*gotKey = r.Header.Get("Authorization")
+			resp := ChatResponse{
+				Choices: []Choice{{Message: Message{Role: "assistant", Content: name}, FinishReason: "stop"}},
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(resp)
+		}))
+	}
+
+	var cloudKey, localKey, fastKey string
+	cloud := newServer("cloud", &cloudKey)
+	defer cloud.Close()
+	local := newServer("local", &localKey)
+	defer local.Close()
+	fast := newServer("fast", &fastKey)
+	defer fast.Close()
+
+	client := NewClient(cloud.URL, "cloud-key", "gpt-4o",
+		WithModelPrefix("local-", local.URL, "local-key"),
+		WithModelPrefix("local-fast-", fast.URL, "fast-key"),
+	)
+
+	tests := []struct {
+		model    string
+		expected string
+		key      *string
+		auth     string
+	}{
+		{"gpt-4o", "cloud", &cloudKey, "Bearer cloud-key"},
+		{"local-llama", "local", &localKey, "Bearer local-key"},
+		{"local-fast-qwen", "fast", &fastKey, "Bearer fast-key"},
+	}
+
+	for _, tt := range tests {
+		resp, err := client.Chat(context.Background(), ChatRequest{
+			Model:    tt.model,
+			Messages: []Message{{Role: "user", Content: "Hello"}},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", tt.model, err)
+		}
+		if resp.Choices[0].Message.Content != tt.expected {
+			t.Errorf("Expected %s to be routed to %s, got %s", tt.model, tt.expected, resp.Choices[0].Message.Content)
+		}
+		if *tt.key != tt.auth {
+			t.Errorf("Expected %s to use %q, got %q", tt.model, tt.auth, *tt.key)
+		}
+	}
+}
+
+func TestClient_WithTrimStopSequences(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := ChatResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: "42</answer>"}, FinishReason: "stop"}},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithDefaultStop("</answer>"))
+	result, err := client.SimpleRequest(context.Background(), "", "Hello")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != "42</answer>" {
+		t.Errorf("Expected content to be untouched by default, got %s", result)
+	}
+
+	client = NewClient(server.URL, "test-key", "model", WithDefaultStop("</answer>"), WithTrimStopSequences(true))
+	result, err = client.SimpleRequest(context.Background(), "", "Hello")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != "42" {
+		t.Errorf("Expected stop sequence to be trimmed, got %s", result)
+	}
+}
+
+func TestClient_WithMaxRequestBytes(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithMaxRequestBytes(256))
+	_, err := client.SimpleRequest(context.Background(), "", strings.Repeat("document ", 100))
+
+	if !errors.Is(err, ErrRequestTooLarge) {
+		t.Fatalf("Expected ErrRequestTooLarge, got %v", err)
+	}
+	var tooLarge *RequestTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Size <= 256 || tooLarge.Limit != 256 {
+		t.Errorf("Expected size details in error, got %+v", tooLarge)
+	}
+	if attempts != 0 {
+		t.Errorf("Expected request not to be sent, got %d attempts", attempts)
+	}
+}
+
+func TestClient_RequestWithSchemaStrict(t *testing.T) {
+	replies := []string{`{\"name\":\"Ann\"}`, `{\"name\":\"Ann\",\"age\":30}`}
+	var requests []ChatRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		requests = append(requests, req)
+
+		reply := replies[min(len(requests), len(replies))-1]
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"` + reply + `"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	type person struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+
+	client := NewClient(server.URL, "test-key", "model")
+
+	var p person
+	if err := client.RequestWithSchemaStrict(context.Background(), "sys", "Ann is 30", &p, 3); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if p.Name != "Ann" || p.Age != 30 {
+		t.Errorf("Unexpected result: %+v", p)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(requests))
+	}
+	repair := requests[1].Messages
+	if len(repair) != 4 || repair[2].Role != "assistant" || !strings.Contains(repair[3].Content, `missing required property "age"`) {
+		t.Errorf("Expected corrective messages, got %+v", repair)
+	}
+
+	requests = nil
+	replies = replies[:1]
+	err := client.RequestWithSchemaStrict(context.Background(), "sys", "Ann", &p, 2)
+	if err == nil || !strings.Contains(err.Error(), `missing required property "age"`) {
+		t.Fatalf("Expected last validation error, got %v", err)
+	}
+	if len(requests) != 2 {
+		t.Errorf("Expected 2 attempts, got %d", len(requests))
+	}
+}
+
+func TestClient_RequestWithSchema_UnmarshalError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"` + "```json\\n{\\\"name\\\": Ann}\\n```" + `"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	type person struct {
+		Name string `json:"name"`
+	}
+
+	var p person
+	client := NewClient(server.URL, "test-key", "model")
+	err := client.RequestWithSchema(context.Background(), "sys", "user", &p)
+	if !errors.Is(err, ErrSchemaUnmarshal) {
+		t.Fatalf("Expected ErrSchemaUnmarshal, got %v", err)
+	}
+
+	var unmarshalErr *SchemaUnmarshalError
+	if !errors.As(err, &unmarshalErr) || unmarshalErr.Content != `{"name": Ann}` {
+		t.Errorf("Expected cleaned content in error, got %+v", unmarshalErr)
+	}
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("Expected underlying json error, got %v", err)
+	}
+}
+
+func TestClient_WithStrictResponseDecoding(t *testing.T) {
+	body := `{"status":"failed","message":"quota exceeded"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithStrictResponseDecoding())
+	_, err := client.SimpleRequest(context.Background(), "sys", "user")
+	var shapeErr *ResponseShapeError
+	if !errors.Is(err, ErrUnexpectedResponseShape) || !errors.As(err, &shapeErr) {
+		t.Fatalf("Expected ResponseShapeError, got %v", err)
+	}
+	if strings.Join(shapeErr.Fields, ",") != "message,status" || shapeErr.Body != body {
+		t.Errorf("Unexpected error details: %+v", shapeErr)
+	}
+
+	body = `"oops"`
+	if _, err := client.SimpleRequest(context.Background(), "sys", "user"); !errors.Is(err, ErrUnexpectedResponseShape) {
+		t.Errorf("Expected ErrUnexpectedResponseShape for non-object, got %v", err)
+	}
+
+	body = `{"id":"chatcmpl-1","object":"chat.completion","created":1,"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`
+	if content, err := client.SimpleRequest(context.Background(), "sys", "user"); err != nil || content != "ok" {
+		t.Errorf("Expected provider fields to be accepted, got %q, %v", content, err)
+	}
+
+	body = `{"status":"failed"}`
+	client = NewClient(server.URL, "test-key", "model")
+	if _, err := client.SimpleRequest(context.Background(), "sys", "user"); !errors.Is(err, ErrNoChoices) {
+		t.Errorf("Expected ErrNoChoices without option, got %v", err)
+	}
+}
+
+func TestClient_RequestWithDecoder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"` +
+			"```xml\\n<item><name>pen</name></item>\\n```" + `"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	type item struct {
+		Name string `xml:"name"`
+	}
+	decode := func(content string, into interface{}) error {
+		return xml.Unmarshal([]byte(content), into)
+	}
+
+	var it item
+	client := NewClient(server.URL, "test-key", "model")
+	if err := client.RequestWithDecoder(context.Background(), "sys", "user", decode, &it); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if it.Name != "pen" {
+		t.Errorf("Expected pen, got %q", it.Name)
+	}
+
+	var got string
+	client = NewClient(server.URL, "test-key", "model", WithCodeFenceLanguages("yaml"))
+	err := client.RequestWithDecoder(context.Background(), "sys", "user", func(content string, _ interface{}) error {
+		got = content
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got != "xml\n<item><name>pen</name></item>" {
+		t.Errorf("Expected unlisted fence language to be kept, got %q", got)
+	}
+}
+
+func TestClient_RequestWithSchemaVote(t *testing.T) {
+	type answer struct {
+		City  string `json:"city"`
+		Score int    `json:"score"`
+	}
+
+	contents := []string{
+		`{"city":"Paris","score":1}`,
+		"```json\n{\"score\": 2, \"city\": \"Lyon\"}\n```",
+		`not json`,
+		`{"score":2,"city":"Lyon"}`,
+		`{"city":"Nice","score":3}`,
+	}
+
+	var gotN int
+	var served []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		gotN = req.N
+
+		resp := ChatResponse{}
+		for _, content := range served {
+			resp.Choices = append(resp.Choices, Choice{Message: Message{Role: "assistant", Content: content}})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model")
+
+	served = contents[:4]
+	var result answer
+	if err := client.RequestWithSchemaVote(context.Background(), "Extract", "text", &result, 4); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if gotN != 4 {
+		t.Errorf("Expected n to be 4, got %d", gotN)
+	}
+	if result.City != "Lyon" {
+		t.Errorf("Expected majority answer 'Lyon', got %+v", result)
+	}
+
+	served = []string{contents[4], contents[0]}
+	result = answer{}
+	if err := client.RequestWithSchemaVote(context.Background(), "Extract", "text", &result, 2); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.City != "Nice" {
+		t.Errorf("Expected tie to be broken by first occurrence, got %+v", result)
+	}
+
+	served = []string{"not json"}
+	if err := client.RequestWithSchemaVote(context.Background(), "Extract", "text", &result, 1); err == nil {
+		t.Error("Expected error when no choice can be parsed")
+	}
+}
+
+func TestClient_WithDefaultServiceTier(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ServiceTier != ServiceTierFlex {
+			t.Errorf("Expected service tier 'flex', got %s", req.ServiceTier)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"service_tier":"flex","choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithDefaultServiceTier(ServiceTierFlex))
+	resp, err := client.Chat(context.Background(), ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.ServiceTier != ServiceTierFlex {
+		t.Errorf("Expected echoed service tier 'flex', got %s", resp.ServiceTier)
+	}
+}
+
+func TestClient_WithDefaultPromptCacheKey(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	req := ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}}
+
+	client := NewClient(server.URL, "test-key", "model")
+	if _, err := client.Chat(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := body["prompt_cache_key"]; ok {
+		t.Errorf("Expected prompt_cache_key to be omitted, got %v", body["prompt_cache_key"])
+	}
+
+	client = NewClient(server.URL, "test-key", "model", WithDefaultPromptCacheKey("tenant-1"))
+	if _, err := client.Chat(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if body["prompt_cache_key"] != "tenant-1" {
+		t.Errorf("Expected default prompt_cache_key, got %v", body["prompt_cache_key"])
+	}
+
+	req.PromptCacheKey = "session-42"
+	if _, err := client.Chat(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if body["prompt_cache_key"] != "session-42" {
+		t.Errorf("Expected request prompt_cache_key to be kept, got %v", body["prompt_cache_key"])
+	}
+}
+
+func TestClient_WithDefaultTopK(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	req := ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}}
+
+	client := NewClient(server.URL, "test-key", "model")
+	if _, err := client.Chat(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := body["top_k"]; ok {
+		t.Errorf("Expected top_k to be omitted, got %v", body["top_k"])
+	}
+
+	client = NewClient(server.URL, "test-key", "model", WithDefaultTopK(40))
+	if _, err := client.Chat(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if body["top_k"] != float64(40) {
+		t.Errorf("Expected default top_k 40, got %v", body["top_k"])
+	}
+
+	zero := 0
+	req.TopK = &zero
+	if _, err := client.Chat(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if body["top_k"] != float64(0) {
+		t.Errorf("Expected explicit top_k 0 to be sent, got %v", body["top_k"])
+	}
+}
+
+func TestChatRequest_LocalSamplingFields(t *testing.T) {
+	data, err := json.Marshal(ChatRequest{Model: "model"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, field := range []string{"top_k", "min_p", "repetition_penalty"} {
+		if strings.Contains(string(data), field) {
+			t.Errorf("Expected %s to be omitted when unset, got %s", field, data)
+		}
+	}
+
+	minP, penalty := float32(0.05), float32(1.1)
+	data, err = json.Marshal(ChatRequest{Model: "model", MinP: &minP, RepetitionPenalty: &penalty})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(string(data), `"min_p":0.05`) || !strings.Contains(string(data), `"repetition_penalty":1.1`) {
+		t.Errorf("Expected min_p and repetition_penalty to be sent, got %s", data)
+	}
+}
+
+func TestClient_WithStrictFinishReason(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"truncat"},"finish_reason":"length"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model")
+	if _, err := client.SimpleRequest(context.Background(), "", "Hello"); err != nil {
+		t.Fatalf("Expected non-stop finish to be accepted by default, got %v", err)
+	}
+
+	client = NewClient(server.URL, "test-key", "model", WithStrictFinishReason())
+	resp, err := client.Chat(context.Background(), ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}})
+	if !errors.Is(err, ErrUnexpectedFinish) {
+		t.Fatalf("Expected ErrUnexpectedFinish, got %v", err)
+	}
+
+	var finishErr *UnexpectedFinishError
+	if !errors.As(err, &finishErr) || finishErr.Reason != "length" || finishErr.Index != 0 {
+		t.Errorf("Unexpected error details: %+v", finishErr)
+	}
+	if len(resp.Choices) != 1 || resp.Choices[0].Message.Content != "truncat" {
+		t.Errorf("Expected partial response to be returned, got %+v", resp)
+	}
+}
+
+func TestClient_WithStrictFinishReason_ToolCalls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{}"}}]},"finish_reason":"tool_calls"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithStrictFinishReason())
+	req := ChatRequest{Messages: []Message{{Role: "user", Content: "Weather?"}}}
+
+	if _, err := client.Chat(context.Background(), req); !errors.Is(err, ErrUnexpectedFinish) {
+		t.Errorf("Expected ErrUnexpectedFinish without tools, got %v", err)
+	}
+
+	req.Tools = []Tool{{Type: "function", Function: FunctionDefinition{Name: "weather"}}}
+	if _, err := client.Chat(context.Background(), req); err != nil {
+		t.Errorf("Expected tool_calls to be accepted with tools, got %v", err)
+	}
+}
+
+func TestClient_WithAuthScheme(t *testing.T) {
+	var gotHeader http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithAuthScheme("Token"))
+	if _, err := client.SimpleRequest(context.Background(), "", "Hello"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := gotHeader.Get("Authorization"); got != "Token test-key" {
+		t.Errorf("Expected 'Token test-key', got %s", got)
+	}
+
+	var dump bytes.Buffer
+	client = NewClient(server.URL, "test-key", "model", WithAuthHeader("api-key"), WithAuthScheme(""), WithDebugDump(&dump))
+	if _, err := client.SimpleRequest(context.Background(), "", "Hello"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := gotHeader.Get("api-key"); got != "test-key" {
+		t.Errorf("Expected raw key in api-key header, got %s", got)
+	}
+	if gotHeader.Get("Authorization") != "" {
+		t.Errorf("Expected no Authorization header, got %s", gotHeader.Get("Authorization"))
+	}
+	if strings.Contains(dump.String(), "test-key") {
+		t.Errorf("Expected custom auth header to be redacted in dump:\n%s", dump.String())
+	}
+	if cfg := client.Config(); cfg.Headers["api-key"] != "********" {
+		t.Errorf("Expected masked api-key header in config, got %v", cfg.Headers)
+	}
+}
+
+func TestClient_WithQueryParam(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("api-version"); got != "2024-06-01" {
+			t.Errorf("Expected api-version query param, got %q", r.URL.RawQuery)
+		}
+		if got := r.URL.Query()["feature"]; len(got) != 2 {
+			t.Errorf("Expected two feature values, got %v", got)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model",
+		WithQueryParam("api-version", "2024-06-01"),
+		WithQueryParam("feature", "a"),
+		WithQueryParam("feature", "b"),
+	)
+	if _, err := client.SimpleRequest(context.Background(), "", "Hello"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if cfg := client.Config(); !strings.HasSuffix(cfg.Endpoint, "?api-version=2024-06-01&feature=a&feature=b") {
+		t.Errorf("Expected query params in config endpoint, got %s", cfg.Endpoint)
+	}
+}
+
+func TestClient_WithTimeoutPerToken(t *testing.T) {
+	client := NewClient("http://localhost", "test-key", "model", WithTimeoutPerToken(10*time.Millisecond))
+
+	ctx, cancel := client.withTokenDeadline(context.Background(), context.Background(), ChatRequest{MaxTokens: 1000})
+	defer cancel()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		t.Fatal("Expected deadline to be set")
+	}
+	if remaining := time.Until(deadline); remaining < 39*time.Second || remaining > 40*time.Second {
+		t.Errorf("Expected deadline about 40s away, got %s", remaining)
+	}
+
+	parent, parentCancel := context.WithTimeout(context.Background(), time.Second)
+	defer parentCancel()
+	ctx, cancel = client.withTokenDeadline(parent, parent, ChatRequest{MaxTokens: 1000})
+	defer cancel()
+	if ctx != parent {
+		t.Error("Expected caller deadline to be kept")
+	}
+
+	ctx, cancel = client.withTokenDeadline(context.Background(), context.Background(), ChatRequest{})
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("Expected no deadline without token limit")
+	}
+}
+
+func TestClient_WithTimeoutPerTokenUsageGrace(t *testing.T) {
+	client := NewClient("http://localhost", "test-key", "model",
+		WithTimeoutPerToken(10*time.Millisecond),
+		WithUsageGracePeriod(time.Second),
+	)
+
+	parent, parentCancel := context.WithTimeout(context.Background(), time.Second)
+	defer parentCancel()
+	graceCtx, _, stop := client.withUsageGrace(parent)
+	defer stop()
+
+	ctx, cancel := client.withTokenDeadline(graceCtx, parent, ChatRequest{MaxTokens: 1000})
+	defer cancel()
+	if ctx != graceCtx {
+		t.Error("Expected caller deadline to disable per-token deadline under grace period")
+	}
+}
+
+func TestClient_WithModelFallback(t *testing.T) {
+	var models []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		models = append(models, req.Model)
+
+		w.Header().Set("Content-Type", "application/json")
+		switch req.Model {
+		case "gpt-5":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"message":"The model gpt-5 does not exist","code":"model_not_found"}}`))
+		case "gpt-4.1":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"model 'gpt-4.1' not found"}`))
+		default:
+			w.Write([]byte(`{"model":"` + req.Model + `","choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "gpt-5", WithModelFallback("gpt-4.1", "gpt-4o-mini"))
+	resp, err := client.Chat(context.Background(), ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.Model != "gpt-4o-mini" {
+		t.Errorf("Expected response from gpt-4o-mini, got %s", resp.Model)
+	}
+	if strings.Join(models, ",") != "gpt-5,gpt-4.1,gpt-4o-mini" {
+		t.Errorf("Unexpected model sequence: %v", models)
+	}
+
+	models = nil
+	client = NewClient(server.URL, "test-key", "gpt-5")
+	if _, err := client.Chat(context.Background(), ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}}); err == nil {
+		t.Error("Expected error without fallback")
+	}
+	if len(models) != 1 {
+		t.Errorf("Expected single attempt without fallback, got %v", models)
+	}
+}
+
+func TestClient_Chat_UnexpectedStream(t *testing.T) {
+	server := newStreamServer(t, "Hello")
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model")
+	_, err := client.Chat(context.Background(), ChatRequest{
+		Messages: []Message{{Role: "user", Content: "Hello"}},
+		Stream:   true,
+	})
+	if !errors.Is(err, ErrUnexpectedStream) {
+		t.Errorf("Expected ErrUnexpectedStream, got %v", err)
+	}
+}
+
+func TestClient_WithDeadlinePropagation(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	req := ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}}
+
+	client := NewClient(server.URL, "test-key", "model", WithDeadlinePropagation(""))
+	if _, err := client.Chat(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got.Get(DeadlineHeaderMilliseconds) != "" {
+		t.Errorf("Expected no deadline header without deadline, got %s", got.Get(DeadlineHeaderMilliseconds))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := client.Chat(ctx, req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ms, err := strconv.Atoi(got.Get(DeadlineHeaderMilliseconds))
+	if err != nil || ms <= 4000 || ms > 5000 {
+		t.Errorf("Expected remaining milliseconds close to 5000, got %q", got.Get(DeadlineHeaderMilliseconds))
+	}
+
+	client = NewClient(server.URL, "test-key", "model", WithDeadlinePropagation(DeadlineHeaderGRPC))
+	if _, err := client.Chat(ctx, req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if value := got.Get(DeadlineHeaderGRPC); !strings.HasSuffix(value, "m") {
+		t.Errorf("Expected grpc-timeout in milliseconds, got %q", value)
+	}
+}
+
+func TestClient_WithRedactedHeaders(t *testing.T) {
+	client := NewClient("http://localhost", "test-key", "model",
+		WithAuthHeader("X-Gateway-Key"),
+		WithRedactedHeaders("x-session-token"),
+	)
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer secret")
+	header.Set("Api-Key", "secret")
+	header.Set("X-Api-Key", "secret")
+	header.Set("X-Gateway-Key", "secret")
+	header.Set("X-Session-Token", "secret")
+	header.Set("Content-Type", "application/json")
+
+	redacted := client.redactHeaders(header)
+	for name := range header {
+		if name == "Content-Type" {
+			continue
+		}
+		if redacted.Get(name) != redactedValue {
+			t.Errorf("Expected %s to be redacted, got %s", name, redacted.Get(name))
+		}
+	}
+	if redacted.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected Content-Type to be kept, got %s", redacted.Get("Content-Type"))
+	}
+	if header.Get("Authorization") != "Bearer secret" {
+		t.Error("Expected original headers to be untouched")
+	}
+}
+
+func TestClient_WithOutboundFilter(t *testing.T) {
+	var sent []Message
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var req ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		sent = req.Messages
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	errBlocked := errors.New("blocked")
+	client := NewClient(server.URL, "test-key", "model", WithOutboundFilter(func(messages []Message) ([]Message, error) {
+		for i, msg := range messages {
+			if strings.Contains(msg.Content, "forbidden") {
+				return nil, errBlocked
+			}
+			messages[i].Content = strings.ReplaceAll(msg.Content, "alice@example.com", "[EMAIL]")
+		}
+		return messages, nil
+	}))
+
+	messages := []Message{{Role: "user", Content: "Mail alice@example.com"}}
+	if _, err := client.Chat(context.Background(), ChatRequest{Messages: messages}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if sent[0].Content != "Mail [EMAIL]" {
+		t.Errorf("Expected content to be scrubbed, got %s", sent[0].Content)
+	}
+	if messages[0].Content != "Mail alice@example.com" {
+		t.Errorf("Expected caller messages to be untouched, got %s", messages[0].Content)
+	}
+
+	_, err := client.Chat(context.Background(), ChatRequest{Messages: []Message{{Role: "user", Content: "forbidden"}}})
+	if !errors.Is(err, errBlocked) {
+		t.Errorf("Expected filter error, got %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected rejected request not to be sent, got %d requests", requests)
+	}
+}
+
+func TestClient_WithHTTPTrace(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	var gotConn, firstByte int
+	trace := &httptrace.ClientTrace{
+		GotConn:              func(httptrace.GotConnInfo) { gotConn++ },
+		GotFirstResponseByte: func() { firstByte++ },
+	}
+
+	client := NewClient(server.URL, "test-key", "model", WithHTTPTrace(trace))
+	if _, err := client.SimpleRequest(context.Background(), "", "Hello"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if gotConn != 1 || firstByte != 1 {
+		t.Errorf("Expected trace hooks to fire once, got GotConn=%d GotFirstResponseByte=%d", gotConn, firstByte)
+	}
+}
+
+func TestClient_WithLocale(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Accept-Language"); got != "fr-FR" {
+			t.Errorf("Expected Accept-Language 'fr-FR', got %q", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"bonjour"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithLocale("fr-FR"))
+	if _, err := client.SimpleRequest(context.Background(), "", "Hello"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+// roundTripperFunc адаптирует функцию к http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestClient_WithHostTransport(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	})
+	public := httptest.NewServer(handler)
+	defer public.Close()
+	internal := httptest.NewServer(handler)
+	defer internal.Close()
+
+	var hostCalls, wrapperCalls []string
+	internalURL, _ := url.Parse(internal.URL)
+
+	client := NewClient(public.URL, "test-key", "gpt",
+		WithModelPrefix("local-", internal.URL, "local-key"),
+		WithHostTransport(strings.ToUpper(internalURL.Host), roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			hostCalls = append(hostCalls, req.URL.Host)
+			return http.DefaultTransport.RoundTrip(req)
+		})),
+		WithTransportWrapper(func(next http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				wrapperCalls = append(wrapperCalls, req.URL.Host)
+				return next.RoundTrip(req)
+			})
+		}),
+	)
+
+	for _, model := range []string{"gpt", "local-llama"} {
+		_, err := client.Chat(context.Background(), ChatRequest{Model: model, Messages: []Message{{Role: "user", Content: "Hello"}}})
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", model, err)
+		}
+	}
+
+	if len(hostCalls) != 1 || hostCalls[0] != internalURL.Host {
+		t.Errorf("Expected only the internal host to use its transport, got %v", hostCalls)
+	}
+	if len(wrapperCalls) != 2 {
+		t.Errorf("Expected wrapper to see both hosts, got %v", wrapperCalls)
+	}
+}
+
+func TestClient_WithTransportWrapper(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	var calls []string
+	var gotProto string
+	wrapper := func(name string) func(http.RoundTripper) http.RoundTripper {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, name)
+				resp, err := next.RoundTrip(req)
+				if err == nil {
+					gotProto = resp.Proto
+				}
+				return resp, err
+			})
+		}
+	}
+
+	client := NewClient(server.URL, "test-key", "model",
+		WithHttpClient(server.Client()),
+		WithTransportWrapper(wrapper("inner")),
+		WithForceHTTP1(),
+		WithTransportWrapper(wrapper("outer")),
+	)
+	if _, err := client.SimpleRequest(context.Background(), "", "Hello"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if strings.Join(calls, ",") != "outer,inner" {
+		t.Errorf("Expected wrappers to run outer first, got %v", calls)
+	}
+	if gotProto != "HTTP/1.1" {
+		t.Errorf("Expected wrapped transport to keep WithForceHTTP1, got %s", gotProto)
+	}
+}
+
+func TestClient_WithModelDefaults(t *testing.T) {
+	var got ChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = ChatRequest{}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "gpt-4o",
+		WithModelDefaults("gpt-4o", ChatRequestDefaults{Temperature: 0.7, MaxTokens: 256}),
+		WithModelDefaults("o1", ChatRequestDefaults{ReasoningEffort: ReasoningEffortHigh, MaxTokens: 1024}),
+		WithDefaultReasoningEffort(ReasoningEffortLow),
+	)
+	messages := []Message{{Role: "user", Content: "Hello"}}
+
+	if _, err := client.Chat(context.Background(), ChatRequest{Messages: messages}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got.Temperature != 0.7 || got.MaxTokens != 256 {
+		t.Errorf("Expected gpt-4o defaults, got temperature=%v max_tokens=%d", got.Temperature, got.MaxTokens)
+	}
+
+	if _, err := client.Chat(context.Background(), ChatRequest{Model: "gpt-4o", Messages: messages, Temperature: 0.1}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got.Temperature != 0.1 {
+		t.Errorf("Expected explicit temperature to win, got %v", got.Temperature)
+	}
+
+	if _, err := client.Chat(context.Background(), ChatRequest{Model: "o1", Messages: messages}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got.Temperature != 0 || got.ReasoningEffort != ReasoningEffortHigh || got.MaxCompletionTokens != 1024 || got.MaxTokens != 0 {
+		t.Errorf("Expected o1 defaults normalized to max_completion_tokens, got %+v", got)
+	}
+}
+
+func TestValidateToolMessages(t *testing.T) {
+	call := ToolCall{ID: "call_1", Type: "function", Function: FunctionCall{Name: "lookup", Arguments: "{}"}}
+
+	valid := []Message{
+		{Role: "system", Content: "Replayed history"},
+		{Role: "assistant", ToolCalls: []ToolCall{call}},
+		{Role: "tool", ToolCallID: "call_1", Content: "result"},
+		{Role: "user", Content: "Continue"},
+	}
+	if err := validateToolMessages(valid); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	replayed := []Message{
+		{Role: "tool", ToolCallID: "call_0", Content: "stored result"},
+		{Role: "user", Content: "Continue"},
+		{Role: "assistant", ToolCalls: []ToolCall{call}},
+		{Role: "tool", ToolCallID: "call_1", Content: "result"},
+	}
+	if err := validateToolMessages(replayed); err != nil {
+		t.Errorf("Expected tool message before the first assistant turn to be accepted, got %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		messages []Message
+	}{
+		{"no tool_call_id", []Message{{Role: "assistant", ToolCalls: []ToolCall{call}}, {Role: "tool", Content: "result"}}},
+		{"unknown id", []Message{{Role: "assistant", ToolCalls: []ToolCall{call}}, {Role: "tool", ToolCallID: "call_2"}}},
+		{"no tool_call_id before assistant", []Message{{Role: "tool", Content: "result"}, {Role: "assistant", ToolCalls: []ToolCall{call}}}},
+		{"after assistant without calls", []Message{{Role: "assistant", Content: "hi"}, {Role: "tool", ToolCallID: "call_1"}}},
+	}
+	for _, tt := range tests {
+		if err := validateToolMessages(tt.messages); !errors.Is(err, ErrInvalidToolMessage) {
+			t.Errorf("%s: expected ErrInvalidToolMessage, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestClient_Chat_ToolMessages(t *testing.T) {
+	var got map[string]interface{}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model")
+
+	_, err := client.Chat(context.Background(), ChatRequest{Messages: []Message{
+		{Role: "assistant", Content: "hi"},
+		{Role: "tool", ToolCallID: "call_1", Content: "orphan"},
+	}})
+	if !errors.Is(err, ErrInvalidToolMessage) {
+		t.Fatalf("Expected ErrInvalidToolMessage, got %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("Expected invalid request not to be sent, got %d requests", requests)
+	}
+
+	_, err = client.Chat(context.Background(), ChatRequest{Messages: []Message{
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_1", Type: "function", Function: FunctionCall{Name: "lookup", Arguments: "{}"}}}},
+		{Role: "tool", ToolCallID: "call_1", Content: "result"},
+	}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	messages := got["messages"].([]interface{})
+	if id := messages[1].(map[string]interface{})["tool_call_id"]; id != "call_1" {
+		t.Errorf("Expected tool_call_id to be serialized, got %v", id)
+	}
+}
+
+func TestClient_WithChatPathFunc(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openai/deployments/gpt-4o/chat/completions" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("api-version") != "2024-06-01" {
+			t.Errorf("Expected query params to compose with the path, got %q", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "gpt-4o",
+		WithChatPathFunc(func(model string) string {
+			return "/openai/deployments/" + model + "/chat/completions"
+		}),
+		WithQueryParam("api-version", "2024-06-01"),
+	)
+	if _, err := client.SimpleRequest(context.Background(), "", "Hello"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if cfg := client.Config(); cfg.Endpoint != server.URL+"/openai/deployments/gpt-4o/chat/completions?api-version=2024-06-01" {
+		t.Errorf("Unexpected config endpoint: %s", cfg.Endpoint)
+	}
+}
+
+func TestClient_WithUseJSONNumber(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"{\"id\":9007199254740993,\"meta\":{\"ref\":9007199254740993}}"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	type record struct {
+		ID   int64                  `json:"id"`
+		Meta map[string]interface{} `json:"meta"`
+	}
+
+	var plain record
+	client := NewClient(server.URL, "test-key", "model")
+	if err := client.RequestWithSchema(context.Background(), "", "Hello", &plain); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := plain.Meta["ref"].(float64); !ok {
+		t.Errorf("Expected float64 by default, got %T", plain.Meta["ref"])
+	}
+
+	var precise record
+	client = NewClient(server.URL, "test-key", "model", WithUseJSONNumber())
+	if err := client.RequestWithSchema(context.Background(), "", "Hello", &precise); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if precise.ID != 9007199254740993 {
+		t.Errorf("Expected typed field to be unaffected, got %d", precise.ID)
+	}
+	if ref, ok := precise.Meta["ref"].(json.Number); !ok || ref.String() != "9007199254740993" {
+		t.Errorf("Expected json.Number 9007199254740993, got %T %v", precise.Meta["ref"], precise.Meta["ref"])
+	}
+}
+
+func TestClient_WithBodyLogSampler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"sampled reply"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	var never bytes.Buffer
+	client := NewClient(server.URL, "test-key", "model", WithBodyLogSampler(0, &never))
+	for i := 0; i < 5; i++ {
+		if _, err := client.SimpleRequest(context.Background(), "", "Hello"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if never.Len() != 0 {
+		t.Errorf("Expected nothing to be sampled at rate 0, got:\n%s", never.String())
+	}
+
+	var always bytes.Buffer
+	client = NewClient(server.URL, "test-key", "model", WithBodyLogSampler(1, &always))
+	if _, err := client.SimpleRequest(context.Background(), "", "Hello"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	dump := always.String()
+	if !strings.Contains(dump, `"content":"Hello"`) || !strings.Contains(dump, "sampled reply") {
+		t.Errorf("Expected request and response bodies in sample:\n%s", dump)
+	}
+	if strings.Contains(dump, "test-key") {
+		t.Errorf("Expected API key to be redacted:\n%s", dump)
+	}
+}
+
+func TestClient_WithAfterResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"[gw] hello"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithAfterResponse(func(resp *ChatResponse) error {
+		for i := range resp.Choices {
+			resp.Choices[i].Message.Content = strings.TrimPrefix(resp.Choices[i].Message.Content, "[gw] ")
+		}
+		return nil
+	}))
+	result, err := client.SimpleRequest(context.Background(), "", "Hello")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != "hello" {
+		t.Errorf("Expected hook to normalize content, got %q", result)
+	}
+
+	errRejected := errors.New("rejected")
+	client = NewClient(server.URL, "test-key", "model", WithAfterResponse(func(*ChatResponse) error {
+		return errRejected
+	}))
+	if _, err := client.SimpleRequest(context.Background(), "", "Hello"); !errors.Is(err, errRejected) {
+		t.Errorf("Expected hook error, got %v", err)
+	}
+}
+
+func TestClient_WithMinAvgLogprob(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if !req.Logprobs || req.TopLogprobs != 2 {
+			t.Errorf("Expected logprobs in request, got %v/%d", req.Logprobs, req.TopLogprobs)
+		}
+
+		logprob := "-2.5"
+		if atomic.AddInt32(&calls, 1) == 3 {
+			logprob = "-0.1"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"hi"},"finish_reason":"stop",` +
+			`"logprobs":{"content":[{"token":"h","logprob":` + logprob + `},{"token":"i","logprob":-0.1}]}}]}`))
+	}))
+	defer server.Close()
+
+	req := ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}, Logprobs: true, TopLogprobs: 2}
+
+	client := NewClient(server.URL, "test-key", "model",
+		WithMinAvgLogprob(-1),
+		WithMaxRetries(2),
+		WithBackoff(time.Millisecond, time.Millisecond),
+	)
+	resp, err := client.Chat(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if avg, ok := resp.Choices[0].Logprobs.AvgLogprob(); !ok || avg != -0.1 {
+		t.Errorf("Expected confident response, got %v/%v", avg, ok)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	client = NewClient(server.URL, "test-key", "model",
+		WithMinAvgLogprob(-1),
+		WithMaxRetries(1),
+		WithBackoff(time.Millisecond, time.Millisecond),
+	)
+	_, err = client.Chat(context.Background(), req)
+	var lowErr *LowConfidenceError
+	if !errors.Is(err, ErrLowConfidence) || !errors.As(err, &lowErr) {
+		t.Fatalf("Expected ErrLowConfidence, got %v", err)
+	}
+	if lowErr.AvgLogprob != -1.3 || lowErr.Threshold != -1 {
+		t.Errorf("Unexpected error details: %+v", lowErr)
+	}
+}
+
+func TestClient_WithUsageExtractor(t *testing.T) {
+	body := `{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"meta":{"billing":{"input":7,"output":3}}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	extract := func(raw json.RawMessage) (Usage, bool) {
+		var payload struct {
+			Meta struct {
+				Billing *struct {
+					Input  int `json:"input"`
+					Output int `json:"output"`
+				} `json:"billing"`
+			} `json:"meta"`
+		}
+		if err := json.Unmarshal(raw, &payload); err != nil || payload.Meta.Billing == nil {
+			return Usage{}, false
+		}
+		b := payload.Meta.Billing
+		return Usage{PromptTokens: b.Input, CompletionTokens: b.Output, TotalTokens: b.Input + b.Output}, true
+	}
+
+	client := NewClient(server.URL, "test-key", "model", WithUsageExtractor(extract))
+	req := ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}}
+
+	resp, err := client.Chat(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.Usage.TotalTokens != 10 || resp.Usage.PromptTokens != 7 {
+		t.Errorf("Expected extracted usage, got %+v", resp.Usage)
+	}
+	if resp.Choices[0].Message.Content != "ok" {
+		t.Errorf("Expected content to be decoded, got %q", resp.Choices[0].Message.Content)
+	}
+
+	body = `{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2},"meta":{"billing":{"input":7,"output":3}}}`
+	resp, err = client.Chat(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.Usage.TotalTokens != 2 {
+		t.Errorf("Expected standard usage to take precedence, got %+v", resp.Usage)
+	}
+}
+
+func TestClient_HeaderPrecedence(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model",
+		WithLocale("ru-RU"),
+		WithGlobalHeaders(http.Header{
+			"X-Level":         {"client"},
+			"X-Client":        {"1"},
+			"Accept-Language": {"en-US"},
+		}),
+	)
+
+	ctx := ContextWithHeaders(context.Background(), http.Header{"X-Level": {"outer"}, "X-Context": {"1"}})
+	ctx = ContextWithHeaders(ctx, http.Header{"x-level": {"context"}})
+
+	req := ChatRequest{
+		Messages: []Message{{Role: "user", Content: "Hello"}},
+		Headers:  http.Header{"X-Level": {"request"}},
+	}
+	if _, err := client.Chat(ctx, req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if level := got.Values("X-Level"); len(level) != 1 || level[0] != "request" {
+		t.Errorf("Expected request-level header to win, got %v", level)
+	}
+	if got.Get("X-Client") != "1" || got.Get("X-Context") != "1" {
+		t.Errorf("Expected headers from all levels, got %v", got)
+	}
+	if got.Get("Accept-Language") != "en-US" {
+		t.Errorf("Expected client-level header to override default, got %q", got.Get("Accept-Language"))
+	}
+
+	req.Headers = nil
+	if _, err := client.Chat(ctx, req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got.Get("X-Level") != "context" {
+		t.Errorf("Expected innermost context header to win, got %q", got.Get("X-Level"))
+	}
+
+	if _, err := client.Chat(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got.Get("X-Level") != "client" {
+		t.Errorf("Expected client-level header, got %q", got.Get("X-Level"))
+	}
+}
+
+func TestClient_WithOnRetryExhausted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	type call struct {
+		req ChatRequest
+		err error
+	}
+	var calls []call
+
+	client := NewClient(server.URL, "test-key", "model",
+		WithMaxRetries(1),
+		WithBackoff(time.Millisecond, time.Millisecond),
+		WithOnRetryExhausted(func(ctx context.Context, req ChatRequest, lastErr error) {
+			calls = append(calls, call{req: req, err: lastErr})
+		}),
+	)
+
+	_, err := client.Chat(context.Background(), ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}})
+	if !errors.Is(err, ErrMaxRetriesExceeded) {
+		t.Fatalf("Expected ErrMaxRetriesExceeded, got %v", err)
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected last StatusError to be wrapped, got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "max retries exceeded: ") {
+		t.Errorf("Unexpected error message: %v", err)
+	}
+
+	if len(calls) != 1 {
+		t.Fatalf("Expected hook to be called once, got %d", len(calls))
+	}
+	if calls[0].req.Model != "model" || calls[0].err != err {
+		t.Errorf("Expected final request and error, got %+v", calls[0])
+	}
+}
+
+func TestClient_WithSoftErrorDetector(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Degraded", "1")
+		w.Write([]byte(`{"error":"rate limited, degraded result","choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithSoftErrorDetector(func(resp *ChatResponse, header http.Header) error {
+		if len(resp.Error) == 0 {
+			return nil
+		}
+		var message string
+		json.Unmarshal(resp.Error, &message)
+		return fmt.Errorf("soft error (degraded=%s): %s", header.Get("X-Degraded"), message)
+	}))
+
+	resp, err := client.Chat(context.Background(), ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}})
+	if err != nil {
+		t.Fatalf("Expected soft error not to fail the call, got %v", err)
+	}
+	if resp.Choices[0].Message.Content != "ok" {
+		t.Errorf("Expected usable choices, got %+v", resp.Choices)
+	}
+	if resp.SoftError == nil || resp.SoftError.Error() != "soft error (degraded=1): rate limited, degraded result" {
+		t.Errorf("Unexpected soft error: %v", resp.SoftError)
+	}
+}
+
+func TestClient_WithProfile(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	send := func(client *Client, req ChatRequest) map[string]interface{} {
+		t.Helper()
+		req.Messages = []Message{{Role: "user", Content: "Hello"}}
+		if _, err := client.Chat(context.Background(), req); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return body
+	}
+
+	got := send(NewClient(server.URL, "test-key", "model", WithProfile(ProfileDeterministic)), ChatRequest{})
+	if got["temperature"] != float64(0) || got["seed"] != float64(ProfileSeed) {
+		t.Errorf("Expected explicit temperature 0 and seed, got %v", got)
+	}
+	if _, ok := got["top_p"]; ok {
+		t.Errorf("Expected no top_p, got %v", got["top_p"])
+	}
+
+	got = send(NewClient(server.URL, "test-key", "model", WithProfile(ProfileCreative)), ChatRequest{})
+	if got["temperature"] != 0.9 || got["top_p"] != 0.95 {
+		t.Errorf("Unexpected creative parameters: %v", got)
+	}
+	if _, ok := got["seed"]; ok {
+		t.Errorf("Expected no seed, got %v", got["seed"])
+	}
+
+	client := NewClient(server.URL, "test-key", "model",
+		WithProfile(ProfileCreative),
+		WithProfile(ProfileDeterministic),
+		WithDefaultSeed(7),
+	)
+	got = send(client, ChatRequest{})
+	if got["seed"] != float64(7) || got["temperature"] != float64(0) {
+		t.Errorf("Expected later option to override profile, got %v", got)
+	}
+	if _, ok := got["top_p"]; ok {
+		t.Errorf("Expected profile to reset top_p, got %v", got["top_p"])
+	}
+
+	seed := 1
+	got = send(client, ChatRequest{Temperature: 0.3, Seed: &seed})
+	if got["temperature"] != 0.3 || got["seed"] != float64(1) {
+		t.Errorf("Expected request values to win, got %v", got)
+	}
+
+	got = send(NewClient(server.URL, "test-key", "model"), ChatRequest{})
+	if _, ok := got["temperature"]; ok {
+		t.Errorf("Expected temperature to be omitted without defaults, got %v", got["temperature"])
+	}
+}
+
+func TestClient_WithTemperatureClamp(t *testing.T) {
+	var logged []float32
+	client := NewClient("http://example.com", "test-key", "model",
+		WithTemperatureClamp(0.2, 1.5),
+		WithTemperatureClampLogger(func(model string, requested, clamped float32) {
+			logged = append(logged, requested, clamped)
+		}),
+	)
+
+	if req := client.prepareRequest(context.Background(), ChatRequest{Temperature: 5}); req.Temperature != 1.5 {
+		t.Errorf("Expected temperature clamped to 1.5, got %v", req.Temperature)
+	}
+	if req := client.prepareRequest(context.Background(), ChatRequest{Temperature: 0.7}); req.Temperature != 0.7 {
+		t.Errorf("Expected temperature in range to be kept, got %v", req.Temperature)
+	}
+	if req := client.prepareRequest(context.Background(), ChatRequest{}); req.Temperature != 0 {
+		t.Errorf("Expected unset temperature to be kept, got %v", req.Temperature)
+	}
+	if len(logged) != 2 || logged[0] != 5 || logged[1] != 1.5 {
+		t.Errorf("Expected one clamp to be logged, got %v", logged)
+	}
+
+	client = NewClient("http://example.com", "test-key", "model",
+		WithDefaultTemperature(0), WithTemperatureClamp(0.2, 1))
+	if req := client.prepareRequest(context.Background(), ChatRequest{}); req.Temperature != 0.2 || req.explicitTemperature {
+		t.Errorf("Expected explicit zero temperature clamped to 0.2, got %+v", req)
+	}
+}
+
+func TestClient_WithProviderExtras(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model",
+		WithProviderExtras(map[string]interface{}{"safe_prompt": true}),
+		WithProviderExtras(map[string]interface{}{
+			"moderation": map[string]interface{}{"categories": []string{"hate"}, "threshold": 0.5},
+			"model":      "ignored",
+			"safe_mode":  false,
+		}),
+	)
+	if _, err := client.Chat(context.Background(), ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if body["safe_prompt"] != true || body["safe_mode"] != false {
+		t.Errorf("Expected boolean extras, got %v", body)
+	}
+	moderation, ok := body["moderation"].(map[string]interface{})
+	if !ok || moderation["threshold"] != 0.5 || moderation["categories"].([]interface{})[0] != "hate" {
+		t.Errorf("Expected nested extras, got %v", body["moderation"])
+	}
+	if body["model"] != "model" {
+		t.Errorf("Expected request field to take precedence, got %v", body["model"])
+	}
+	if len(body["messages"].([]interface{})) != 1 {
+		t.Errorf("Expected request fields to be kept, got %v", body)
+	}
+}
+
+func TestClient_Chat_ContentFilterResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"prompt_filter_results":[{"prompt_index":0,"content_filter_results":{
+				"hate":{"filtered":false,"severity":"safe"},
+				"jailbreak":{"filtered":false,"detected":false}}}],
+			"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop",
+				"content_filter_results":{"violence":{"filtered":true,"severity":"medium"}}}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model")
+	resp, err := client.Chat(context.Background(), ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(resp.PromptFilterResults) != 1 {
+		t.Fatalf("Expected 1 prompt filter result, got %d", len(resp.PromptFilterResults))
+	}
+	prompt := resp.PromptFilterResults[0].ContentFilterResults
+	if prompt.Hate == nil || prompt.Hate.Severity != "safe" || prompt.Sexual != nil {
+		t.Errorf("Unexpected prompt filter results: %+v", prompt)
+	}
+	if prompt.Jailbreak == nil || prompt.Jailbreak.Detected == nil || *prompt.Jailbreak.Detected {
+		t.Errorf("Unexpected jailbreak result: %+v", prompt.Jailbreak)
+	}
+
+	choice := resp.Choices[0].ContentFilterResults
+	if choice == nil || choice.Violence == nil || !choice.Violence.Filtered || choice.Violence.Severity != "medium" {
+		t.Errorf("Unexpected choice filter results: %+v", choice)
+	}
+
+	data, _ := json.Marshal(ChatResponse{Choices: []Choice{{FinishReason: "stop"}}})
+	if strings.Contains(string(data), "filter_results") {
+		t.Errorf("Expected filter results to be omitted, got %s", data)
+	}
+}
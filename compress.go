@@ -0,0 +1,36 @@
+package llmclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"sync/atomic"
+)
+
+// defaultCompressionThreshold - минимальный размер тела запроса для сжатия
+// WithRequestCompression
+const defaultCompressionThreshold = 8 << 10
+
+// requestCompression хранит настройки сжатия тела запроса
+type requestCompression struct {
+	threshold int
+	// rejected устанавливается, когда сервер ответил 415 на сжатый запрос
+	rejected atomic.Bool
+}
+
+// compressBody сжимает body в gzip, если включен WithRequestCompression, тело не
+// меньше порога и сервер ранее не отклонял сжатые запросы
+func (c *Client) compressBody(body []byte) ([]byte, bool) {
+	if c.compression == nil || len(body) < c.compression.threshold || c.compression.rejected.Load() {
+		return body, false
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(body); err != nil {
+		return body, false
+	}
+	if err := w.Close(); err != nil {
+		return body, false
+	}
+	return buf.Bytes(), true
+}
@@ -0,0 +1,102 @@
+package llmclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient_WithRequestCompression(t *testing.T) {
+	var encodings []string
+	var prompts []string
+	acceptGzip := true
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := r.Header.Get("Content-Encoding")
+		encodings = append(encodings, encoding)
+
+		body := io.Reader(r.Body)
+		if encoding == "gzip" {
+			if !acceptGzip {
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				return
+			}
+			gz, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("Failed to read gzip body: %v", err)
+				return
+			}
+			body = gz
+		}
+
+		var req ChatRequest
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		prompts = append(prompts, req.Messages[1].Content)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	large := strings.Repeat("context ", 2000)
+	client := NewClient(server.URL, "test-key", "model", WithRequestCompression())
+	for _, prompt := range []string{"small", large} {
+		if _, err := client.SimpleRequest(context.Background(), "sys", prompt); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if strings.Join(encodings, ",") != ",gzip" || prompts[1] != large {
+		t.Errorf("Expected only the large body to be compressed, got %q", encodings)
+	}
+
+	encodings, prompts, acceptGzip = nil, nil, false
+	client = NewClient(server.URL, "test-key", "model", WithRequestCompression(), WithConcurrencyPerModel(map[string]int{"model": 1}))
+	for i := 0; i < 2; i++ {
+		if _, err := client.SimpleRequest(context.Background(), "sys", large); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if strings.Join(encodings, ",") != "gzip,," || len(prompts) != 2 {
+		t.Errorf("Expected one uncompressed retry after 415 and no compression afterwards, got %q", encodings)
+	}
+}
+
+func TestClient_WithRequestCompression_RejectedAttemptRecorded(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			w.Write([]byte("gzip is not supported"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	var dump bytes.Buffer
+	var metrics []RequestMetrics
+	client := NewClient(server.URL, "test-key", "model",
+		WithRequestCompression(),
+		WithDebugDump(&dump),
+		WithMetricsHook(func(m RequestMetrics) { metrics = append(metrics, m) }),
+	)
+	if _, err := client.SimpleRequest(context.Background(), "sys", strings.Repeat("context ", 2000)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if strings.Count(dump.String(), ">>> POST") != 2 || !strings.Contains(dump.String(), "415") ||
+		!strings.Contains(dump.String(), "gzip is not supported") {
+		t.Errorf("Expected both attempts and the 415 response in the dump, got:\n%s", dump.String())
+	}
+	if len(metrics) != 1 || metrics[0].Retries != 1 || metrics[0].StatusCode != http.StatusOK {
+		t.Errorf("Expected the rejected attempt counted once as a retry, got %+v", metrics)
+	}
+}
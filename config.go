@@ -0,0 +1,42 @@
+package llmclient
+
+import "strings"
+
+// Config представляет снимок эффективных настроек клиента.
+// API ключ и заголовок авторизации в снимке замаскированы.
+type Config struct {
+	BaseURL    string            `json:"base_url"`
+	Endpoint   string            `json:"endpoint"`
+	Model      string            `json:"model"`
+	APIKey     string            `json:"api_key"`
+	Headers    map[string]string `json:"headers"`
+	MaxRetries int               `json:"max_retries"`
+}
+
+// Config возвращает снимок настроек, которые клиент использует после применения опций
+func (c *Client) Config() Config {
+	endpoint := c.baseURL + c.chatPath(c.model)
+	if len(c.queryParams) > 0 {
+		endpoint += "?" + c.queryParams.Encode()
+	}
+
+	return Config{
+		BaseURL:  c.baseURL,
+		Endpoint: endpoint,
+		Model:    c.model,
+		APIKey:   maskAPIKey(c.apiKey),
+		Headers: map[string]string{
+			"Content-Type": c.contentType,
+			c.authHeader:   c.authValue(maskAPIKey(c.apiKey)),
+		},
+		MaxRetries: c.maxRetries,
+	}
+}
+
+// maskAPIKey маскирует ключ, оставляя только короткий префикс и суффикс
+func maskAPIKey(key string) string {
+	if len(key) <= 12 {
+		return strings.Repeat("*", len(key))
+	}
+	return key[:3] + "..." + key[len(key)-4:]
+}
@@ -0,0 +1,119 @@
+package llmclient
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// Типы частей содержимого сообщения
+const (
+	ContentPartText       = "text"
+	ContentPartInputAudio = "input_audio"
+)
+
+// Модальности ответа для ChatRequest.Modalities
+const (
+	ModalityText  = "text"
+	ModalityAudio = "audio"
+)
+
+// ContentPart представляет часть содержимого сообщения: текст или аудио
+type ContentPart struct {
+	Type       string      `json:"type"`
+	Text       string      `json:"text,omitempty"`
+	InputAudio *InputAudio `json:"input_audio,omitempty"`
+}
+
+// InputAudio содержит аудио во входном сообщении в base64
+type InputAudio struct {
+	Data   string `json:"data"`
+	Format string `json:"format"`
+}
+
+// AudioConfig задает голос и формат аудио в ответе
+type AudioConfig struct {
+	Voice  string `json:"voice"`
+	Format string `json:"format"`
+}
+
+// AudioOutput содержит аудио ответа модели. Data передается в base64.
+// Для ссылки на аудио в следующих сообщениях диалога достаточно ID
+type AudioOutput struct {
+	ID         string `json:"id"`
+	Data       string `json:"data,omitempty"`
+	Transcript string `json:"transcript,omitempty"`
+	ExpiresAt  int64  `json:"expires_at,omitempty"`
+}
+
+// Bytes декодирует аудио ответа из base64
+func (a *AudioOutput) Bytes() ([]byte, error) {
+	return base64.StdEncoding.DecodeString(a.Data)
+}
+
+// TextPart создает текстовую часть содержимого
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: ContentPartText, Text: text}
+}
+
+// AudioPart создает часть содержимого с аудио в формате format (например, "wav" или "mp3")
+func AudioPart(data []byte, format string) ContentPart {
+	return ContentPart{
+		Type:       ContentPartInputAudio,
+		InputAudio: &InputAudio{Data: base64.StdEncoding.EncodeToString(data), Format: format},
+	}
+}
+
+// MarshalJSON отправляет Parts массивом в поле content, если они заданы
+func (m Message) MarshalJSON() ([]byte, error) {
+	type message Message
+	if len(m.Parts) == 0 {
+		return json.Marshal(message(m))
+	}
+
+	return json.Marshal(struct {
+		message
+		Content []ContentPart `json:"content"`
+	}{message: message(m), Content: m.Parts})
+}
+
+// UnmarshalJSON принимает content строкой или массивом частей. Для массива
+// части сохраняются в Parts, а текст частей объединяется в Content.
+// Рассуждения читаются из reasoning_content или reasoning
+func (m *Message) UnmarshalJSON(data []byte) error {
+	type message Message
+	aux := struct {
+		*message
+		Content          json.RawMessage `json:"content"`
+		ReasoningContent string          `json:"reasoning_content"`
+		Reasoning        string          `json:"reasoning"`
+	}{message: (*message)(m)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	m.ReasoningContent = aux.ReasoningContent
+	if m.ReasoningContent == "" {
+		m.ReasoningContent = aux.Reasoning
+	}
+
+	content := bytes.TrimSpace(aux.Content)
+	switch {
+	case len(content) == 0 || bytes.Equal(content, []byte("null")):
+		return nil
+	case content[0] == '[':
+		if err := json.Unmarshal(content, &m.Parts); err != nil {
+			return err
+		}
+		var text strings.Builder
+		for _, part := range m.Parts {
+			text.WriteString(part.Text)
+		}
+		m.Content = text.String()
+		return nil
+	default:
+		return json.Unmarshal(content, &m.Content)
+	}
+}
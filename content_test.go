@@ -0,0 +1,87 @@
+package llmclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMessage_JSON(t *testing.T) {
+	data, err := json.Marshal(Message{Role: "user", Content: "Hi"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(data) != `{"role":"user","content":"Hi"}` {
+		t.Errorf("Expected text-only message to be unchanged, got %s", data)
+	}
+
+	data, err = json.Marshal(Message{Role: "user", Parts: []ContentPart{TextPart("Transcribe"), AudioPart([]byte("RIFF"), "wav")}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `{"role":"user","content":[{"type":"text","text":"Transcribe"},{"type":"input_audio","input_audio":{"data":"UklGRg==","format":"wav"}}]}`
+	if string(data) != expected {
+		t.Errorf("Expected parts in content:\n%s\ngot:\n%s", expected, data)
+	}
+
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(msg.Parts) != 2 || msg.Content != "Transcribe" || msg.Parts[1].InputAudio.Format != "wav" {
+		t.Errorf("Unexpected decoded message: %+v", msg)
+	}
+
+	if err := json.Unmarshal([]byte(`{"role":"assistant","content":null}`), &msg); err != nil {
+		t.Fatalf("Unexpected error for null content: %v", err)
+	}
+}
+
+func TestClient_Chat_Audio(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&req)
+		if modalities, _ := req["modalities"].([]interface{}); len(modalities) != 2 {
+			t.Errorf("Expected modalities, got %v", req["modalities"])
+		}
+		if audio, _ := req["audio"].(map[string]interface{}); audio["voice"] != "alloy" {
+			t.Errorf("Expected audio config, got %v", req["audio"])
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":null,"audio":{"id":"audio_1","data":"aGVsbG8=","transcript":"hello","expires_at":1700000000}},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "gpt-4o-audio-preview")
+	resp, err := client.Chat(context.Background(), ChatRequest{
+		Messages:   []Message{{Role: "user", Parts: []ContentPart{AudioPart([]byte("RIFF"), "wav")}}},
+		Modalities: []string{ModalityText, ModalityAudio},
+		Audio:      &AudioConfig{Voice: "alloy", Format: "wav"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	audio := resp.Choices[0].Message.Audio
+	if audio == nil || audio.Transcript != "hello" || audio.ID != "audio_1" {
+		t.Fatalf("Unexpected audio output: %+v", audio)
+	}
+	if data, err := audio.Bytes(); err != nil || string(data) != "hello" {
+		t.Errorf("Expected decoded audio 'hello', got %q (%v)", data, err)
+	}
+}
+
+func TestChatRequest_TextOnlyOmitsAudio(t *testing.T) {
+	data, _ := json.Marshal(ChatRequest{Model: "gpt-4o", Messages: []Message{{Role: "user", Content: "Hi"}}})
+	var fields map[string]interface{}
+	json.Unmarshal(data, &fields)
+	if _, ok := fields["modalities"]; ok {
+		t.Error("Expected modalities to be omitted")
+	}
+	if _, ok := fields["audio"]; ok {
+		t.Error("Expected audio to be omitted")
+	}
+}
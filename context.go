@@ -0,0 +1,90 @@
+package llmclient
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// seedContextKey - ключ контекста для seed запроса
+type seedContextKey struct{}
+
+// ContextWithSeed возвращает контекст с seed, который Chat подставит
+// в запросы без явно заданного Seed
+func ContextWithSeed(ctx context.Context, seed int) context.Context {
+	return context.WithValue(ctx, seedContextKey{}, seed)
+}
+
+// seedFromContext извлекает seed из контекста
+func seedFromContext(ctx context.Context) (int, bool) {
+	seed, ok := ctx.Value(seedContextKey{}).(int)
+	return seed, ok
+}
+
+// headersContextKey - ключ контекста для заголовков ContextWithHeaders
+type headersContextKey struct{}
+
+// requestHeadersContextKey - ключ контекста для заголовков ChatRequest.Headers
+type requestHeadersContextKey struct{}
+
+// ContextWithHeaders возвращает контекст с заголовками, которые будут добавлены
+// к запросам, выполняемым с этим контекстом (например, заголовки трассировки).
+// Заголовки из вложенных вызовов заменяют одноименные заголовки внешних
+func ContextWithHeaders(ctx context.Context, headers http.Header) context.Context {
+	return context.WithValue(ctx, headersContextKey{}, mergeHeaders(headersFromContext(ctx), headers))
+}
+
+// headersFromContext извлекает заголовки ContextWithHeaders из контекста
+func headersFromContext(ctx context.Context) http.Header {
+	headers, _ := ctx.Value(headersContextKey{}).(http.Header)
+	return headers
+}
+
+// withRequestHeaders передает заголовки запроса в doRequest через контекст
+func withRequestHeaders(ctx context.Context, headers http.Header) context.Context {
+	if len(headers) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, requestHeadersContextKey{}, headers)
+}
+
+// requestHeadersFromContext извлекает заголовки запроса из контекста
+func requestHeadersFromContext(ctx context.Context) http.Header {
+	headers, _ := ctx.Value(requestHeadersContextKey{}).(http.Header)
+	return headers
+}
+
+// callerDeadlineContextKey - ключ контекста для дедлайна вызывающего,
+// отвязанного WithUsageGracePeriod
+type callerDeadlineContextKey struct{}
+
+// withCallerDeadline сохраняет дедлайн ctx в контексте, чтобы он был доступен
+// после context.WithoutCancel
+func withCallerDeadline(detached, ctx context.Context) context.Context {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return detached
+	}
+	return context.WithValue(detached, callerDeadlineContextKey{}, deadline)
+}
+
+// deadlineFromContext возвращает ближайший из дедлайнов ctx и вызывающего
+func deadlineFromContext(ctx context.Context) (time.Time, bool) {
+	deadline, ok := ctx.Deadline()
+	if caller, found := ctx.Value(callerDeadlineContextKey{}).(time.Time); found && (!ok || caller.Before(deadline)) {
+		return caller, true
+	}
+	return deadline, ok
+}
+
+// mergeHeaders возвращает копию base, в которой значения заголовков из override
+// заменяют одноименные
+func mergeHeaders(base, override http.Header) http.Header {
+	merged := make(http.Header, len(base)+len(override))
+	for _, headers := range []http.Header{base, override} {
+		for name, values := range headers {
+			merged[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+		}
+	}
+	return merged
+}
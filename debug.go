@@ -0,0 +1,104 @@
+package llmclient
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+)
+
+// redactedValue подставляется вместо значений секретных заголовков
+const redactedValue = "[REDACTED]"
+
+// defaultRedactedHeaders - заголовки с секретами, которые маскируются всегда.
+// Заголовок из WithAuthHeader добавляется к ним автоматически
+var defaultRedactedHeaders = []string{"Authorization", "Api-Key", "X-Api-Key"}
+
+// redactHeaders возвращает копию заголовков, в которой значения секретных
+// заголовков заменены на redactedValue. Все хуки, выводящие заголовки, должны
+// проходить через этот метод
+func (c *Client) redactHeaders(header http.Header) http.Header {
+	redacted := header.Clone()
+	for name, values := range redacted {
+		if !c.redactedHeaders[http.CanonicalHeaderKey(name)] {
+			continue
+		}
+		for i := range values {
+			values[i] = redactedValue
+		}
+	}
+	return redacted
+}
+
+// dumpWriters возвращает получателей дампа для очередного запроса: WithDebugDump
+// получает все запросы, WithBodyLogSampler - случайную долю запросов
+func (c *Client) dumpWriters() []io.Writer {
+	var writers []io.Writer
+	if c.debugDump != nil {
+		writers = append(writers, c.debugDump)
+	}
+	if c.bodyLogWriter != nil && rand.Float64() < c.bodyLogRate {
+		writers = append(writers, c.bodyLogWriter)
+	}
+	return writers
+}
+
+// dumpRequest записывает метод, URL, заголовки и тело запроса в w
+func (c *Client) dumpRequest(w io.Writer, req *http.Request, body []byte) {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, ">>> %s %s\n", req.Method, req.URL)
+	writeHeaders(&buf, c.redactHeaders(req.Header))
+	buf.WriteString("\n")
+	buf.Write(body)
+	buf.WriteString("\n\n")
+
+	w.Write(buf.Bytes())
+}
+
+// dumpResponse записывает статус и заголовки ответа в w и подменяет тело так,
+// чтобы прочитанные байты дублировались в w
+func (c *Client) dumpResponse(w io.Writer, resp *http.Response) {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "<<< %s %s\n", resp.Proto, resp.Status)
+	writeHeaders(&buf, c.redactHeaders(resp.Header))
+	buf.WriteString("\n")
+	w.Write(buf.Bytes())
+
+	resp.Body = &teeBody{
+		Reader: io.TeeReader(resp.Body, w),
+		body:   resp.Body,
+		w:      w,
+	}
+}
+
+// writeHeaders записывает заголовки в отсортированном порядке
+func writeHeaders(buf *bytes.Buffer, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range header[name] {
+			fmt.Fprintf(buf, "%s: %s\n", name, value)
+		}
+	}
+}
+
+// teeBody дублирует тело ответа в writer и завершает дамп при закрытии
+type teeBody struct {
+	io.Reader
+	body io.Closer
+	w    io.Writer
+}
+
+// Close закрывает исходное тело ответа
+func (b *teeBody) Close() error {
+	b.w.Write([]byte("\n\n"))
+	return b.body.Close()
+}
@@ -0,0 +1,339 @@
+package llmclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoChoices возвращается, когда ответ API не содержит вариантов ответа
+	ErrNoChoices = errors.New("no choices in response")
+
+	// ErrRequestTooLarge возвращается, когда тело запроса превышает WithMaxRequestBytes
+	ErrRequestTooLarge = errors.New("request too large")
+
+	// ErrUnexpectedFinish возвращается при WithStrictFinishReason, когда генерация
+	// завершилась не по причине "stop"
+	ErrUnexpectedFinish = errors.New("unexpected finish reason")
+
+	// ErrStreamTimeout возвращается, когда поток не завершился за WithStreamTotalTimeout
+	ErrStreamTimeout = errors.New("stream timeout")
+
+	// ErrUnexpectedStream возвращается, когда на обычный запрос пришел потоковый
+	// ответ (например, из-за Stream: true в запросе к Chat)
+	ErrUnexpectedStream = errors.New("unexpected event stream response: use ChatStream for streaming requests")
+
+	// ErrInvalidToolMessage возвращается, когда у сообщения с ролью tool нет tool_call_id
+	// или, после первого хода ассистента, оно не ссылается на вызов инструмента из
+	// предшествующего сообщения ассистента
+	ErrInvalidToolMessage = errors.New("invalid tool message")
+
+	// ErrCircuitOpen возвращается без обращения к серверу, пока разомкнут
+	// предохранитель WithCircuitBreaker
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+
+	// ErrLowConfidence возвращается, когда средний логарифм вероятности токенов
+	// ответа ниже порога WithMinAvgLogprob после всех повторов
+	ErrLowConfidence = errors.New("low confidence response")
+
+	// ErrInvalidToolArguments возвращается, когда аргументы вызова инструмента
+	// не соответствуют его схеме
+	ErrInvalidToolArguments = errors.New("invalid tool arguments")
+
+	// ErrToolRoundsExceeded возвращается, когда ChatWithTools не получил ответа
+	// без вызовов инструментов за WithMaxToolRounds обращений к модели
+	ErrToolRoundsExceeded = errors.New("tool rounds exceeded")
+
+	// ErrSchemaUnmarshal возвращается, когда ответ модели не удалось разобрать
+	// в структуру схемы
+	ErrSchemaUnmarshal = errors.New("failed to unmarshal response into schema")
+
+	// ErrModelNotAvailable возвращается при WithValidateModel, если модели клиента
+	// нет в списке ListModels
+	ErrModelNotAvailable = errors.New("model not available")
+
+	// ErrUnexpectedResponseShape возвращается при WithStrictResponseDecoding, когда
+	// успешный ответ не похож на ответ чат-комплишенов
+	ErrUnexpectedResponseShape = errors.New("unexpected response shape")
+
+	// ErrMaxRetriesExceeded возвращается (вместе с последней ошибкой попытки),
+	// когда исчерпаны все повторы запроса
+	ErrMaxRetriesExceeded = errors.New("max retries exceeded")
+)
+
+// ToolArgumentsError описывает вызов инструмента с некорректными аргументами
+// или вызов неизвестного инструмента. Соответствует ErrInvalidToolArguments
+// при проверке через errors.Is
+type ToolArgumentsError struct {
+	Tool   string
+	CallID string
+	Err    error
+}
+
+// Error реализует интерфейс error
+func (e *ToolArgumentsError) Error() string {
+	return fmt.Sprintf("invalid arguments for tool %q: %v", e.Tool, e.Err)
+}
+
+// Unwrap возвращает причину ошибки
+func (e *ToolArgumentsError) Unwrap() error {
+	return e.Err
+}
+
+// Is позволяет сравнивать ошибку с ErrInvalidToolArguments
+func (e *ToolArgumentsError) Is(target error) bool {
+	return target == ErrInvalidToolArguments
+}
+
+// SchemaUnmarshalError содержит ответ модели (после удаления блока кода), который
+// не удалось разобрать в структуру схемы, и ошибку разбора. Соответствует
+// ErrSchemaUnmarshal при проверке через errors.Is
+type SchemaUnmarshalError struct {
+	Content string
+	Err     error
+}
+
+// Error реализует интерфейс error
+func (e *SchemaUnmarshalError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrSchemaUnmarshal, e.Err)
+}
+
+// Unwrap возвращает ошибку разбора
+func (e *SchemaUnmarshalError) Unwrap() error {
+	return e.Err
+}
+
+// Is позволяет сравнивать ошибку с ErrSchemaUnmarshal
+func (e *SchemaUnmarshalError) Is(target error) bool {
+	return target == ErrSchemaUnmarshal
+}
+
+// ResponseShapeError описывает успешный ответ, в котором нет поля choices:
+// Fields - поля верхнего уровня, которые вернул сервер, Body - тело ответа.
+// Соответствует ErrUnexpectedResponseShape при проверке через errors.Is
+type ResponseShapeError struct {
+	Fields []string
+	Body   string
+}
+
+// Error реализует интерфейс error
+func (e *ResponseShapeError) Error() string {
+	if e.Fields == nil {
+		return fmt.Sprintf("%v: response is not a JSON object: %s", ErrUnexpectedResponseShape, e.Body)
+	}
+	return fmt.Sprintf("%v: missing \"choices\", got fields %v: %s", ErrUnexpectedResponseShape, e.Fields, e.Body)
+}
+
+// Is позволяет сравнивать ошибку с ErrUnexpectedResponseShape
+func (e *ResponseShapeError) Is(target error) bool {
+	return target == ErrUnexpectedResponseShape
+}
+
+// LowConfidenceError содержит средний логарифм вероятности отклоненного варианта.
+// Соответствует ErrLowConfidence при проверке через errors.Is
+type LowConfidenceError struct {
+	Index      int
+	AvgLogprob float64
+	Threshold  float64
+}
+
+// Error реализует интерфейс error
+func (e *LowConfidenceError) Error() string {
+	return fmt.Sprintf("average logprob %.4f of choice %d is below %.4f", e.AvgLogprob, e.Index, e.Threshold)
+}
+
+// Is позволяет сравнивать ошибку с ErrLowConfidence
+func (e *LowConfidenceError) Is(target error) bool {
+	return target == ErrLowConfidence
+}
+
+// CircuitOpenError сообщает, через сколько предохранитель пропустит пробный запрос.
+// Соответствует ErrCircuitOpen при проверке через errors.Is
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+}
+
+// Error реализует интерфейс error
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker is open, retry after %s", e.RetryAfter)
+}
+
+// Is позволяет сравнивать ошибку с ErrCircuitOpen
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// RequestTooLargeError содержит фактический размер отклоненного запроса.
+// Соответствует ErrRequestTooLarge при проверке через errors.Is
+type RequestTooLargeError struct {
+	Size  int64
+	Limit int64
+}
+
+// Error реализует интерфейс error
+func (e *RequestTooLargeError) Error() string {
+	return fmt.Sprintf("request too large: %d bytes (limit %d)", e.Size, e.Limit)
+}
+
+// Is позволяет сравнивать ошибку с ErrRequestTooLarge
+func (e *RequestTooLargeError) Is(target error) bool {
+	return target == ErrRequestTooLarge
+}
+
+// UnexpectedFinishError содержит причину завершения и индекс варианта ответа.
+// Соответствует ErrUnexpectedFinish при проверке через errors.Is
+type UnexpectedFinishError struct {
+	Reason string
+	Index  int
+}
+
+// Error реализует интерфейс error
+func (e *UnexpectedFinishError) Error() string {
+	return fmt.Sprintf("unexpected finish reason %q in choice %d", e.Reason, e.Index)
+}
+
+// Is позволяет сравнивать ошибку с ErrUnexpectedFinish
+func (e *UnexpectedFinishError) Is(target error) bool {
+	return target == ErrUnexpectedFinish
+}
+
+// StreamTimeoutError сообщает, что поток прерван по WithStreamTotalTimeout, а не
+// по отмене контекста вызывающего. Соответствует ErrStreamTimeout и
+// context.DeadlineExceeded при проверке через errors.Is
+type StreamTimeoutError struct {
+	Timeout time.Duration
+}
+
+// Error реализует интерфейс error
+func (e *StreamTimeoutError) Error() string {
+	return fmt.Sprintf("stream not completed within %s", e.Timeout)
+}
+
+// Is позволяет сравнивать ошибку с ErrStreamTimeout и context.DeadlineExceeded
+func (e *StreamTimeoutError) Is(target error) bool {
+	return target == ErrStreamTimeout || target == context.DeadlineExceeded
+}
+
+// statusErrorBodyLimit - максимальный размер фрагмента тела в StatusError
+const statusErrorBodyLimit = 512
+
+// StatusError описывает неуспешный HTTP ответ, после которого запрос повторялся.
+// Ошибка исчерпания повторов оборачивает последний StatusError
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+// Error реализует интерфейс error
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+// newStatusError создает StatusError, читая начало тела ответа
+func newStatusError(resp *http.Response) *StatusError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, statusErrorBodyLimit))
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		Body:       strings.TrimSpace(string(body)),
+	}
+}
+
+// ErrorResponseParser преобразует тело ответа с ошибкой в ошибку Go
+type ErrorResponseParser func(status int, body []byte) error
+
+// APIError представляет ошибку, возвращенную API
+type APIError struct {
+	StatusCode int
+	Type       string
+	Code       string
+	Param      string
+	Message    string
+	Body       string
+}
+
+// Error реализует интерфейс error
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("API error: status %d, body: %s", e.StatusCode, e.Body)
+	}
+	if e.Code != "" {
+		return fmt.Sprintf("API error: status %d (%s): %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("API error: status %d: %s", e.StatusCode, e.Message)
+}
+
+// openAIErrorEnvelope описывает формат ошибки OpenAI: {"error": {...}}
+type openAIErrorEnvelope struct {
+	Error *struct {
+		Message string          `json:"message"`
+		Type    string          `json:"type"`
+		Param   string          `json:"param"`
+		Code    json.RawMessage `json:"code"`
+	} `json:"error"`
+}
+
+// parseOpenAIError - парсер ошибок по умолчанию, понимающий формат OpenAI
+func parseOpenAIError(status int, body []byte) error {
+	apiErr := &APIError{
+		StatusCode: status,
+		Body:       string(body),
+	}
+
+	var envelope openAIErrorEnvelope
+	if err := json.Unmarshal(body, &envelope); err == nil && envelope.Error != nil {
+		apiErr.Message = envelope.Error.Message
+		apiErr.Type = envelope.Error.Type
+		apiErr.Param = envelope.Error.Param
+		apiErr.Code = rawCodeString(envelope.Error.Code)
+	}
+
+	return apiErr
+}
+
+// rawCodeString приводит поле code (строка, число или null) к строке
+func rawCodeString(raw json.RawMessage) string {
+	code := string(raw)
+	if code == "null" {
+		return ""
+	}
+	return strings.Trim(code, `"`)
+}
+
+// modelNotFoundMarkers - фрагменты сообщений об ошибке, означающие недоступность модели
+var modelNotFoundMarkers = []string{"not found", "does not exist", "not available", "unavailable"}
+
+// isModelNotFound сообщает, что запрос отклонен из-за недоступной модели:
+// код model_not_found или ответ 404/400 с сообщением о модели
+func isModelNotFound(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	if apiErr.Code == "model_not_found" {
+		return true
+	}
+	if apiErr.StatusCode != http.StatusNotFound && apiErr.StatusCode != http.StatusBadRequest {
+		return false
+	}
+
+	message := strings.ToLower(apiErr.Message + " " + apiErr.Body)
+	if !strings.Contains(message, "model") {
+		return false
+	}
+	for _, marker := range modelNotFoundMarkers {
+		if strings.Contains(message, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package llmclient
+
+import (
+	"encoding/json"
+)
+
+// GenerateExample строит пример JSON объекта для структуры instance по ее схеме
+// (см. GenerateSchema): строки заполняются значением "string" или первым значением
+// enum, числа - нулем, массивы содержат minItems (не меньше одного) элементов,
+// а map - один ключ "key". Ссылки $ref разрешаются по корню схемы; рекурсивное поле
+// раскрывается один раз, а его повторное вхождение становится null.
+// Используется в WithSchemaExampleInjection
+func GenerateExample(instance interface{}) ([]byte, error) {
+	schema, err := GenerateSchema(instance)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(exampleFromSchema(schema))
+}
+
+// exampleFromSchema возвращает пример значения, соответствующего схеме
+func exampleFromSchema(schema map[string]interface{}) interface{} {
+	builder := exampleBuilder{refs: schemaValidator{root: schema}, expanding: map[string]bool{"#": true}}
+	return builder.build(schema)
+}
+
+// exampleBuilder строит пример по схеме, разрешая $ref относительно корня.
+// expanding содержит ссылки, раскрываемые на текущем пути, что ограничивает
+// глубину рекурсивных схем
+type exampleBuilder struct {
+	refs      schemaValidator
+	expanding map[string]bool
+}
+
+// build возвращает пример значения, соответствующего схеме
+func (b exampleBuilder) build(schema map[string]interface{}) interface{} {
+	if ref, ok := schema["$ref"].(string); ok {
+		if b.expanding[ref] {
+			return nil
+		}
+		target, err := b.refs.resolve("$", schema)
+		if err != nil {
+			return nil
+		}
+		b.expanding[ref] = true
+		defer delete(b.expanding, ref)
+		return b.build(target)
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[0]
+	}
+
+	switch schema["type"] {
+	case "object":
+		example := make(map[string]interface{})
+		if properties, ok := schema["properties"].(map[string]interface{}); ok {
+			for name, property := range properties {
+				example[name] = b.build(asSchema(property))
+			}
+		} else if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+			example["key"] = b.build(additional)
+		}
+		return example
+	case "array":
+		count := 1
+		if minItems, ok := schema["minItems"].(int); ok && minItems > count {
+			count = minItems
+		}
+		item := asSchema(schema["items"])
+		example := make([]interface{}, count)
+		for i := range example {
+			example[i] = b.build(item)
+		}
+		return example
+	case "string":
+		if _, ok := schema["contentEncoding"]; ok {
+			return ""
+		}
+		return "string"
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return false
+	default:
+		return nil
+	}
+}
+
+// asSchema приводит вложенную схему к map, отсутствующая схема становится пустой
+func asSchema(value interface{}) map[string]interface{} {
+	schema, _ := value.(map[string]interface{})
+	return schema
+}
@@ -0,0 +1,107 @@
+package llmclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGenerateExample(t *testing.T) {
+	type item struct {
+		Tags   []string          `json:"tags" schema:"minItems=2"`
+		Score  float64           `json:"score"`
+		Active *bool             `json:"active,omitempty"`
+		Attrs  map[string]int    `json:"attrs"`
+		Raw    []byte            `json:"raw"`
+		Any    interface{}       `json:"any"`
+		Nested struct{ ID int }  `json:"nested"`
+		Loose  map[string]string `json:"-"`
+	}
+
+	data, err := GenerateExample(&item{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := `{"active":false,"any":null,"attrs":{"key":0},"nested":{"ID":0},"raw":"","score":0,"tags":["string","string"]}`
+	if string(data) != want {
+		t.Errorf("GenerateExample() = %s, want %s", data, want)
+	}
+
+	var decoded item
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Errorf("Expected example to decode into the struct: %v", err)
+	}
+
+	if _, err := GenerateExample(42); err == nil {
+		t.Error("Expected error for non-struct")
+	}
+}
+
+func TestGenerateExample_Refs(t *testing.T) {
+	for _, tt := range []struct {
+		instance interface{}
+		want     string
+	}{
+		{schemaNode{}, `{"next":null,"value":0}`},
+		{schemaList{}, `{"count":0,"head":{"next":null,"value":0}}`},
+		{SchemaTreeNode{}, `{"children":[null],"name":"string"}`},
+	} {
+		data, err := GenerateExample(tt.instance)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("GenerateExample(%T) = %s, want %s", tt.instance, data, tt.want)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"loop": map[string]interface{}{"$ref": "#/$defs/a"}},
+		"$defs": map[string]interface{}{
+			"a": map[string]interface{}{"$ref": "#/$defs/b"},
+			"b": map[string]interface{}{"$ref": "#/$defs/a"},
+		},
+	}
+	if example, _ := json.Marshal(exampleFromSchema(schema)); string(example) != `{"loop":null}` {
+		t.Errorf("Expected unresolvable $ref chain to become null, got %s", example)
+	}
+}
+
+func TestClient_WithSchemaExampleInjection(t *testing.T) {
+	var messages []Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		messages = req.Messages
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"{\"name\":\"Ann\"}"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	type person struct {
+		Name string `json:"name"`
+	}
+
+	var p person
+	client := NewClient(server.URL, "test-key", "model", WithSchemaExampleInjection())
+	if err := client.RequestWithSchema(context.Background(), "sys", "Hello", &p); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(messages[0].Content, "sys\n\n") || !strings.HasSuffix(messages[0].Content, "Example of the expected response format:\n{\"name\":\"string\"}") {
+		t.Errorf("Expected example in system prompt, got %q", messages[0].Content)
+	}
+
+	client = NewClient(server.URL, "test-key", "model")
+	if err := client.RequestWithSchema(context.Background(), "sys", "Hello", &p); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if messages[0].Content != "sys" {
+		t.Errorf("Expected system prompt unchanged without option, got %q", messages[0].Content)
+	}
+}
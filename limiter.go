@@ -0,0 +1,84 @@
+package llmclient
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// DefaultModelLimit - ключ WithConcurrencyPerModel, задающий ограничение
+// для моделей, которых нет в карте
+const DefaultModelLimit = "*"
+
+// modelLimiter ограничивает число одновременных запросов отдельно для каждой модели
+type modelLimiter struct {
+	limits map[string]int
+
+	mu   sync.Mutex
+	sems map[string]chan struct{}
+}
+
+// newModelLimiter создает ограничитель по карте модель -> лимит
+func newModelLimiter(limits map[string]int) *modelLimiter {
+	copied := make(map[string]int, len(limits))
+	for model, limit := range limits {
+		copied[model] = limit
+	}
+	return &modelLimiter{limits: copied, sems: make(map[string]chan struct{})}
+}
+
+// semaphore возвращает семафор модели или nil, если модель не ограничена
+func (l *modelLimiter) semaphore(model string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if sem, ok := l.sems[model]; ok {
+		return sem
+	}
+
+	limit, ok := l.limits[model]
+	if !ok {
+		limit, ok = l.limits[DefaultModelLimit]
+	}
+	if !ok || limit <= 0 {
+		l.sems[model] = nil
+		return nil
+	}
+
+	sem := make(chan struct{}, limit)
+	l.sems[model] = sem
+	return sem
+}
+
+// acquire занимает слот модели, ожидая освобождения или отмены ctx.
+// Возвращаемая функция освобождает слот и безопасна для повторного вызова
+func (l *modelLimiter) acquire(ctx context.Context, model string) (func(), error) {
+	sem := l.semaphore(model)
+	if sem == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-sem })
+	}, nil
+}
+
+// releaseBody освобождает слот модели при закрытии тела ответа, чтобы
+// потоковый ответ занимал слот до конца чтения
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+// Close закрывает тело ответа и освобождает слот
+func (b *releaseBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
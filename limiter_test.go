@@ -0,0 +1,102 @@
+package llmclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_WithConcurrencyPerModel(t *testing.T) {
+	var mu sync.Mutex
+	active := make(map[string]int)
+	peak := make(map[string]int)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		mu.Lock()
+		active[req.Model]++
+		if active[req.Model] > peak[req.Model] {
+			peak[req.Model] = active[req.Model]
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		active[req.Model]--
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithConcurrencyPerModel(map[string]int{
+		"gpt-4o":          1,
+		DefaultModelLimit: 2,
+	}))
+
+	var wg sync.WaitGroup
+	for _, model := range []string{"gpt-4o", "gpt-4o", "gpt-4o", "mini", "mini", "mini", "mini"} {
+		wg.Add(1)
+		go func(model string) {
+			defer wg.Done()
+			_, err := client.Chat(context.Background(), ChatRequest{Model: model, Messages: []Message{{Role: "user", Content: "Hello"}}})
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}(model)
+	}
+	wg.Wait()
+
+	if peak["gpt-4o"] != 1 {
+		t.Errorf("Expected at most 1 concurrent gpt-4o request, got %d", peak["gpt-4o"])
+	}
+	if peak["mini"] > 2 {
+		t.Errorf("Expected at most 2 concurrent requests for default limit, got %d", peak["mini"])
+	}
+}
+
+func TestModelLimiter_Release(t *testing.T) {
+	limiter := newModelLimiter(map[string]int{"m": 1})
+
+	release, err := limiter.acquire(context.Background(), "m")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.acquire(ctx, "m"); err != context.DeadlineExceeded {
+		t.Errorf("Expected acquire to wait for a free slot, got %v", err)
+	}
+
+	release()
+	release()
+
+	var acquired atomic.Int32
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		if r, err := limiter.acquire(ctx, "m"); err == nil {
+			acquired.Add(1)
+			defer r()
+		}
+		cancel()
+	}
+	if acquired.Load() != 1 {
+		t.Errorf("Expected double release to free exactly one slot, got %d acquisitions", acquired.Load())
+	}
+
+	if release, err := limiter.acquire(context.Background(), "unlimited"); err != nil {
+		t.Errorf("Expected unlisted model to be unlimited, got %v", err)
+	} else {
+		release()
+	}
+}
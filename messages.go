@@ -0,0 +1,150 @@
+package llmclient
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// collapseSystemMessages объединяет подряд идущие системные сообщения в начале
+// истории в одно, соединяя их содержимое переводом строки
+func collapseSystemMessages(messages []Message) []Message {
+	leading := 0
+	for leading < len(messages) && messages[leading].Role == "system" {
+		leading++
+	}
+	if leading <= 1 {
+		return messages
+	}
+
+	parts := make([]string, 0, leading)
+	for _, msg := range messages[:leading] {
+		parts = append(parts, msg.Content)
+	}
+
+	result := make([]Message, 0, len(messages)-leading+1)
+	result = append(result, Message{Role: "system", Content: strings.Join(parts, "\n")})
+	return append(result, messages[leading:]...)
+}
+
+// validateToolMessages проверяет, что каждое сообщение с ролью tool после первого
+// сообщения ассистента ссылается через tool_call_id на вызов инструмента из
+// предшествующего сообщения ассистента. Так рассогласованная история отклоняется
+// до отправки, а не ответом 400. Сообщения tool до первого хода ассистента
+// (история, восстановленная из хранилища) проверяются только на наличие tool_call_id
+func validateToolMessages(messages []Message) error {
+	calls := make(map[string]bool)
+	assistantSeen := false
+
+	for i, msg := range messages {
+		switch msg.Role {
+		case "assistant":
+			assistantSeen = true
+			for _, call := range msg.ToolCalls {
+				calls[call.ID] = true
+			}
+		case "tool":
+			if msg.ToolCallID == "" {
+				return fmt.Errorf("%w: message %d has no tool_call_id", ErrInvalidToolMessage, i)
+			}
+			if assistantSeen && !calls[msg.ToolCallID] {
+				return fmt.Errorf("%w: message %d references tool_call_id %q not found in any prior assistant message",
+					ErrInvalidToolMessage, i, msg.ToolCallID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Tokenizer возвращает число токенов текста для модели
+type Tokenizer func(text string) int
+
+// EstimateTokens грубо оценивает число токенов текста: один токен на четыре символа
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// messageTokenOverhead - служебные токены каждого сообщения (роль и разметка)
+const messageTokenOverhead = 4
+
+// messageTokens оценивает число токенов сообщения с учетом частей и вызовов инструментов
+func messageTokens(msg Message, tok Tokenizer) int {
+	tokens := messageTokenOverhead + tok(msg.Content)
+	for _, part := range msg.Parts {
+		tokens += tok(part.Text)
+	}
+	for _, call := range msg.ToolCalls {
+		tokens += tok(call.Function.Name) + tok(call.Function.Arguments)
+	}
+	return tokens
+}
+
+// TruncateMessages удаляет самые старые сообщения истории, пока ее размер не уложится
+// в maxTokens. Системные сообщения и последнее сообщение пользователя со всеми
+// следующими за ним не удаляются, поэтому результат может превышать maxTokens.
+// Вместе с вызовом инструментов удаляются и его результаты. При tok == nil
+// используется EstimateTokens. Исходный срез не изменяется
+func TruncateMessages(messages []Message, maxTokens int, tok Tokenizer) []Message {
+	if tok == nil {
+		tok = EstimateTokens
+	}
+	return truncateHistory(messages, maxTokens, func(msg Message) int {
+		return messageTokens(msg, tok)
+	})
+}
+
+// truncateHistory удаляет самые старые незащищенные сообщения, пока суммарная
+// стоимость истории превышает budget (см. TruncateMessages)
+func truncateHistory(messages []Message, budget int, cost func(Message) int) []Message {
+	total := 0
+	for _, msg := range messages {
+		total += cost(msg)
+	}
+	if total <= budget {
+		return messages
+	}
+
+	lastUser := len(messages)
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			lastUser = i
+			break
+		}
+	}
+
+	dropped := make([]bool, len(messages))
+	for i := 0; i < lastUser && total > budget; i++ {
+		if messages[i].Role == "system" {
+			continue
+		}
+		dropped[i] = true
+		total -= cost(messages[i])
+
+		// результаты инструментов без вызова отклоняются провайдерами
+		for i+1 < lastUser && messages[i+1].Role == "tool" {
+			i++
+			dropped[i] = true
+			total -= cost(messages[i])
+		}
+	}
+
+	result := make([]Message, 0, len(messages))
+	for i, msg := range messages {
+		if !dropped[i] {
+			result = append(result, msg)
+		}
+	}
+	return result
+}
+
+// truncateRequestHistory применяет к истории запроса WithHistoryTruncation и WithTokenBudget
+func (c *Client) truncateRequestHistory(messages []Message) []Message {
+	if c.maxHistoryMessages > 0 {
+		messages = truncateHistory(messages, c.maxHistoryMessages, func(Message) int { return 1 })
+	}
+	if c.tokenBudget > 0 {
+		messages = TruncateMessages(messages, c.tokenBudget, c.tokenizer)
+	}
+	return messages
+}
@@ -0,0 +1,85 @@
+package llmclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func roles(messages []Message) string {
+	names := make([]string, len(messages))
+	for i, msg := range messages {
+		names[i] = msg.Role + ":" + msg.Content
+	}
+	return strings.Join(names, " ")
+}
+
+func TestTruncateMessages(t *testing.T) {
+	words := func(text string) int { return len(strings.Fields(text)) }
+	history := []Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "one two three"},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "c1", Function: FunctionCall{Name: "f"}}}},
+		{Role: "tool", ToolCallID: "c1", Content: "result"},
+		{Role: "assistant", Content: "four five"},
+		{Role: "user", Content: "six"},
+	}
+
+	if got := TruncateMessages(history, 1000, words); !reflect.DeepEqual(got, history) {
+		t.Errorf("Expected history to be kept, got %s", roles(got))
+	}
+
+	// overhead 4 на сообщение: system 6, user 7, assistant с вызовом 5, tool 5, assistant 6, user 5
+	got := TruncateMessages(history, 25, words)
+	if roles(got) != "system:be brief assistant:four five user:six" {
+		t.Errorf("Unexpected truncated history: %s", roles(got))
+	}
+	if len(history) != 6 {
+		t.Errorf("Expected source history to be unchanged")
+	}
+
+	got = TruncateMessages(history, 1, words)
+	if roles(got) != "system:be brief user:six" {
+		t.Errorf("Expected system and last user message to be kept, got %s", roles(got))
+	}
+}
+
+func TestClient_WithHistoryTruncation(t *testing.T) {
+	var received ChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithHistoryTruncation(3))
+	_, err := client.Chat(context.Background(), ChatRequest{Messages: []Message{
+		{Role: "system", Content: "s"},
+		{Role: "user", Content: "1"},
+		{Role: "assistant", Content: "2"},
+		{Role: "user", Content: "3"},
+	}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if roles(received.Messages) != "system:s assistant:2 user:3" {
+		t.Errorf("Unexpected sent history: %s", roles(received.Messages))
+	}
+
+	client = NewClient(server.URL, "test-key", "model", WithTokenBudget(10, nil))
+	_, err = client.Chat(context.Background(), ChatRequest{Messages: []Message{
+		{Role: "user", Content: strings.Repeat("a", 100)},
+		{Role: "user", Content: "b"},
+	}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if roles(received.Messages) != "user:b" {
+		t.Errorf("Unexpected sent history: %s", roles(received.Messages))
+	}
+}
@@ -0,0 +1,135 @@
+package llmclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RequestMetrics описывает один логический вызов Chat или ChatStream, включая
+// все повторы, переподключения потока и переходы на резервные модели
+type RequestMetrics struct {
+	// Model - модель последней попытки
+	Model string
+	// Stream равен true для ChatStream
+	Stream bool
+	// RequestBytes - размер тела запроса последней попытки
+	RequestBytes int
+	// ResponseBytes - число прочитанных байт тела ответа последней попытки
+	ResponseBytes int64
+	// TimeToFirstByte - время от начала вызова до первого байта тела ответа
+	// (для потока - до первого фрагмента). Ноль, если тело не читалось
+	TimeToFirstByte time.Duration
+	// Duration - полная длительность вызова
+	Duration time.Duration
+	// Retries - число попыток сверх первой
+	Retries int
+	// StatusCode - HTTP статус последнего ответа или 0, если ответа не было
+	StatusCode int
+	// Err - итоговая ошибка вызова
+	Err error
+}
+
+// metricsContextKey - ключ контекста для сборщика метрик вызова
+type metricsContextKey struct{}
+
+// metricsRecorder собирает метрики одного логического вызова
+type metricsRecorder struct {
+	hook     func(RequestMetrics)
+	start    time.Time
+	attempts int
+
+	mu      sync.Mutex
+	metrics RequestMetrics
+}
+
+// startMetrics создает сборщик метрик вызова и сохраняет его в контексте.
+// Без WithMetricsHook возвращает nil
+func (c *Client) startMetrics(ctx context.Context, stream bool) (context.Context, *metricsRecorder) {
+	if c.metricsHook == nil {
+		return ctx, nil
+	}
+
+	r := &metricsRecorder{hook: c.metricsHook, start: time.Now(), metrics: RequestMetrics{Stream: stream}}
+	return context.WithValue(ctx, metricsContextKey{}, r), r
+}
+
+// metricsFromContext возвращает сборщик метрик вызова или nil
+func metricsFromContext(ctx context.Context) *metricsRecorder {
+	r, _ := ctx.Value(metricsContextKey{}).(*metricsRecorder)
+	return r
+}
+
+// attempt учитывает очередную попытку отправки запроса
+func (r *metricsRecorder) attempt(model string, requestBytes int) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.attempts++
+	r.metrics.Model = model
+	r.metrics.RequestBytes = requestBytes
+	r.metrics.ResponseBytes = 0
+	r.metrics.TimeToFirstByte = 0
+	r.metrics.StatusCode = 0
+}
+
+// response учитывает ответ попытки и подсчитывает байты его тела
+func (r *metricsRecorder) response(resp *http.Response) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	r.metrics.StatusCode = resp.StatusCode
+	r.mu.Unlock()
+
+	resp.Body = &meteredBody{ReadCloser: resp.Body, recorder: r}
+}
+
+// read учитывает n прочитанных байт тела ответа
+func (r *metricsRecorder) read(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.metrics.TimeToFirstByte == 0 {
+		r.metrics.TimeToFirstByte = time.Since(r.start)
+	}
+	r.metrics.ResponseBytes += int64(n)
+}
+
+// finish передает метрики вызова в хук
+func (r *metricsRecorder) finish(err error) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	metrics := r.metrics
+	metrics.Duration = time.Since(r.start)
+	metrics.Retries = max(r.attempts-1, 0)
+	metrics.Err = err
+	r.mu.Unlock()
+
+	r.hook(metrics)
+}
+
+// meteredBody подсчитывает байты, прочитанные из тела ответа
+type meteredBody struct {
+	io.ReadCloser
+	recorder *metricsRecorder
+}
+
+// Read читает тело ответа и учитывает прочитанные байты
+func (b *meteredBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.recorder.read(n)
+	}
+	return n, err
+}
@@ -0,0 +1,86 @@
+package llmclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_WithMetricsHook(t *testing.T) {
+	const reply = `{"model":"m1","choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(reply))
+	}))
+	defer server.Close()
+
+	var observed []RequestMetrics
+	client := NewClient(server.URL, "test-key", "m1",
+		WithBackoff(time.Millisecond, time.Millisecond),
+		WithMetricsHook(func(m RequestMetrics) {
+			observed = append(observed, m)
+		}),
+	)
+
+	if _, err := client.SimpleRequest(context.Background(), "", "Hello"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(observed) != 1 {
+		t.Fatalf("Expected one observation per call, got %d", len(observed))
+	}
+	m := observed[0]
+	if m.Model != "m1" || m.Stream || m.Retries != 1 || m.StatusCode != http.StatusOK || m.Err != nil {
+		t.Errorf("Unexpected metrics: %+v", m)
+	}
+	if m.RequestBytes == 0 || m.ResponseBytes != int64(len(reply)) {
+		t.Errorf("Unexpected sizes: request %d, response %d", m.RequestBytes, m.ResponseBytes)
+	}
+	if m.TimeToFirstByte <= 0 || m.Duration < m.TimeToFirstByte {
+		t.Errorf("Unexpected timings: ttfb %s, duration %s", m.TimeToFirstByte, m.Duration)
+	}
+}
+
+func TestClient_WithMetricsHookStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	var observed []RequestMetrics
+	client := NewClient(server.URL, "test-key", "model", WithMetricsHook(func(m RequestMetrics) {
+		observed = append(observed, m)
+	}))
+
+	err := client.ChatStream(context.Background(), ChatRequest{
+		Messages: []Message{{Role: "user", Content: "Hello"}},
+	}, func(ChatStreamChunk) error { return nil })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(observed) != 1 {
+		t.Fatalf("Expected one observation per call, got %d", len(observed))
+	}
+	m := observed[0]
+	if !m.Stream || m.Retries != 0 || m.StatusCode != http.StatusOK || m.ResponseBytes == 0 {
+		t.Errorf("Unexpected metrics: %+v", m)
+	}
+	if m.Duration-m.TimeToFirstByte < 20*time.Millisecond {
+		t.Errorf("Expected first byte before stream end, got ttfb %s, duration %s", m.TimeToFirstByte, m.Duration)
+	}
+}
@@ -0,0 +1,131 @@
+package llmclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// modelsPath - путь эндпоинта списка моделей
+const modelsPath = "/v1/models"
+
+// Model описывает модель, доступную через API
+type Model struct {
+	ID      string `json:"id"`
+	Object  string `json:"object,omitempty"`
+	Created int64  `json:"created,omitempty"`
+	OwnedBy string `json:"owned_by,omitempty"`
+}
+
+// ListModels возвращает список моделей, доступных с ключом клиента
+func (c *Client) ListModels(ctx context.Context) ([]Model, error) {
+	return c.listModels(ctx, c.defaultEndpoint())
+}
+
+// listModels возвращает список моделей эндпоинта ep
+func (c *Client) listModels(ctx context.Context, ep endpoint) ([]Model, error) {
+	var list struct {
+		Data []Model `json:"data"`
+	}
+	if err := c.doJSONAt(ctx, ep, http.MethodGet, modelsPath, "", nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Data, nil
+}
+
+// modelCheck хранит списки моделей эндпоинтов, полученные для WithValidateModel
+type modelCheck struct {
+	mu    sync.Mutex
+	lists map[endpoint]*modelList
+}
+
+// forEndpoint возвращает кэш списка моделей эндпоинта ep
+func (m *modelCheck) forEndpoint(ep endpoint) *modelList {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.lists == nil {
+		m.lists = make(map[endpoint]*modelList)
+	}
+	list, ok := m.lists[ep]
+	if !ok {
+		list = &modelList{}
+		m.lists[ep] = list
+	}
+	return list
+}
+
+// modelList хранит список моделей одного эндпоинта
+type modelList struct {
+	mu      sync.Mutex
+	models  map[string]bool // nil, пока список не получен
+	loading chan struct{}   // закрывается по окончании выполняющегося запроса списка
+}
+
+// checkModel проверяет, что model (или модель клиента, если model пуст) есть в
+// списке моделей эндпоинта, на который она направляется WithModelPrefix. Список
+// запрашивается один раз для каждого эндпоинта и кэшируется; одновременные вызовы
+// ждут первый запрос, не удерживая блокировку. Ошибка получения списка
+// не кэшируется, и запрос повторяется при следующем вызове
+func (c *Client) checkModel(ctx context.Context, model string) error {
+	if c.modelCheck == nil {
+		return nil
+	}
+	if model == "" {
+		model = c.model
+	}
+
+	ep := c.resolveEndpoint(model)
+	models, err := c.modelCheck.forEndpoint(ep).load(ctx, func(ctx context.Context) ([]Model, error) {
+		return c.listModels(ctx, ep)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list models: %w", err)
+	}
+	if !models[model] {
+		return fmt.Errorf("%w: %q is not in the list of %d available models", ErrModelNotAvailable, model, len(models))
+	}
+	return nil
+}
+
+// load возвращает закэшированный список моделей или получает его через list.
+// Пока один вызов получает список, остальные ждут его результата или отмены ctx
+func (m *modelList) load(ctx context.Context, list func(context.Context) ([]Model, error)) (map[string]bool, error) {
+	for {
+		m.mu.Lock()
+		if m.models != nil {
+			models := m.models
+			m.mu.Unlock()
+			return models, nil
+		}
+		if loading := m.loading; loading != nil {
+			m.mu.Unlock()
+			select {
+			case <-loading:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		loading := make(chan struct{})
+		m.loading = loading
+		m.mu.Unlock()
+
+		available, err := list(ctx)
+
+		m.mu.Lock()
+		m.loading = nil
+		if err == nil {
+			m.models = make(map[string]bool, len(available))
+			for _, model := range available {
+				m.models[model.ID] = true
+			}
+		}
+		models := m.models
+		m.mu.Unlock()
+		close(loading)
+
+		return models, err
+	}
+}
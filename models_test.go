@@ -0,0 +1,159 @@
+package llmclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClient_ListModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/models" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"object":"list","data":[{"id":"gpt-4o","object":"model","owned_by":"openai"}]}`))
+	}))
+	defer server.Close()
+
+	models, err := NewClient(server.URL, "test-key", "gpt-4o").ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(models) != 1 || models[0].ID != "gpt-4o" || models[0].OwnedBy != "openai" {
+		t.Errorf("Unexpected models: %+v", models)
+	}
+}
+
+func TestClient_WithValidateModel(t *testing.T) {
+	var listCalls, chatCalls int
+	listStatus := http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/v1/models" {
+			listCalls++
+			w.WriteHeader(listStatus)
+			w.Write([]byte(`{"data":[{"id":"gpt-4o"}]}`))
+			return
+		}
+		chatCalls++
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "gpt-4o", WithValidateModel(), WithMaxRetries(0))
+	if _, err := client.SimpleRequest(context.Background(), "sys", "user"); err == nil {
+		t.Fatal("Expected error when model list is unavailable")
+	}
+
+	listStatus = http.StatusOK
+	for i := 0; i < 2; i++ {
+		if _, err := client.SimpleRequest(context.Background(), "sys", "user"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if listCalls != 2 || chatCalls != 2 {
+		t.Errorf("Expected list failure to be retried and success cached, got %d list and %d chat calls", listCalls, chatCalls)
+	}
+
+	listCalls, chatCalls = 0, 0
+	client = NewClient(server.URL, "test-key", "gpt-4o-typo", WithValidateModel())
+	for i := 0; i < 2; i++ {
+		_, err := client.SimpleRequest(context.Background(), "sys", "user")
+		if !errors.Is(err, ErrModelNotAvailable) {
+			t.Fatalf("Expected ErrModelNotAvailable, got %v", err)
+		}
+	}
+	if listCalls != 1 || chatCalls != 0 {
+		t.Errorf("Expected cached failure without chat requests, got %d list and %d chat calls", listCalls, chatCalls)
+	}
+}
+
+func TestClient_WithValidateModel_RequestModel(t *testing.T) {
+	var listCalls atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/v1/models" {
+			listCalls.Add(1)
+			<-release
+			w.Write([]byte(`{"data":[{"id":"gpt-4o"},{"id":"gpt-4o-mini"}]}`))
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "gpt-4o", WithValidateModel())
+
+	// Одновременные первые вызовы ждут один запрос списка
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Chat(context.Background(), ChatRequest{Messages: []Message{{Role: "user", Content: "Hi"}}}); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}()
+	}
+	for listCalls.Load() == 0 {
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+
+	if _, err := client.Chat(context.Background(), ChatRequest{Model: "gpt-4o-mini", Messages: []Message{{Role: "user", Content: "Hi"}}}); err != nil {
+		t.Errorf("Unexpected error for available request model: %v", err)
+	}
+	_, err := client.Chat(context.Background(), ChatRequest{Model: "gpt-4o-mnii", Messages: []Message{{Role: "user", Content: "Hi"}}})
+	if !errors.Is(err, ErrModelNotAvailable) {
+		t.Errorf("Expected ErrModelNotAvailable for request model typo, got %v", err)
+	}
+	if got := listCalls.Load(); got != 1 {
+		t.Errorf("Expected one list request, got %d", got)
+	}
+}
+
+func TestClient_WithValidateModel_ModelPrefix(t *testing.T) {
+	newServer := func(models string, listCalls *atomic.Int32) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if r.URL.Path == "/v1/models" {
+				listCalls.Add(1)
+				w.Write([]byte(`{"data":` + models + `}`))
+				return
+			}
+			w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+		}))
+	}
+
+	var cloudCalls, localCalls atomic.Int32
+	cloud := newServer(`[{"id":"gpt-4o"}]`, &cloudCalls)
+	defer cloud.Close()
+	local := newServer(`[{"id":"local-llama"}]`, &localCalls)
+	defer local.Close()
+
+	client := NewClient(cloud.URL, "cloud-key", "gpt-4o",
+		WithModelPrefix("local-", local.URL, "local-key"),
+		WithValidateModel(),
+	)
+
+	for _, model := range []string{"gpt-4o", "local-llama", "local-llama"} {
+		if _, err := client.Chat(context.Background(), ChatRequest{Model: model, Messages: []Message{{Role: "user", Content: "Hi"}}}); err != nil {
+			t.Fatalf("Unexpected error for %s: %v", model, err)
+		}
+	}
+	_, err := client.Chat(context.Background(), ChatRequest{Model: "local-qwen", Messages: []Message{{Role: "user", Content: "Hi"}}})
+	if !errors.Is(err, ErrModelNotAvailable) {
+		t.Errorf("Expected ErrModelNotAvailable for model missing on routed endpoint, got %v", err)
+	}
+	if cloudCalls.Load() != 1 || localCalls.Load() != 1 {
+		t.Errorf("Expected one list request per endpoint, got cloud %d, local %d", cloudCalls.Load(), localCalls.Load())
+	}
+}
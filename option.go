@@ -1,6 +1,15 @@
 package llmclient
 
-import "net/http"
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"time"
+)
 
 // Option определяет функциональную опцию для настройки клиента
 type Option func(*Client)
@@ -17,4 +26,769 @@ func WithMaxRetries(maxRetries int) Option {
 	return func(c *Client) {
 		c.maxRetries = maxRetries
 	}
-}
\ No newline at end of file
+}
+
+// WithErrorResponseParser устанавливает парсер тела ответа с ошибкой.
+// По умолчанию (и при nil) разбирается формат OpenAI и возвращается *APIError
+func WithErrorResponseParser(parser ErrorResponseParser) Option {
+	return func(c *Client) {
+		if parser != nil {
+			c.errorParser = parser
+		}
+	}
+}
+
+// WithSingleSystemMessage включает объединение подряд идущих системных сообщений
+// в начале истории в одно - для провайдеров, принимающих только одно системное сообщение
+func WithSingleSystemMessage() Option {
+	return func(c *Client) {
+		c.singleSystemMessage = true
+	}
+}
+
+// WithDefaultStop задает стоп-последовательности, которые добавляются в запрос,
+// если в нем не указаны собственные
+func WithDefaultStop(stops ...string) Option {
+	return func(c *Client) {
+		c.defaultStop = stops
+	}
+}
+
+// WithDefaultStopAlways включает добавление стоп-последовательностей по умолчанию
+// даже в запросы с собственными Stop (с удалением дубликатов и с учетом лимита в 4 значения)
+func WithDefaultStopAlways(always bool) Option {
+	return func(c *Client) {
+		c.defaultStopAlways = always
+	}
+}
+
+// WithRetryOnEmptyChoices включает повтор запроса, если API вернул успешный ответ
+// без вариантов. Повтор расходует попытку из WithMaxRetries
+func WithRetryOnEmptyChoices(retry bool) Option {
+	return func(c *Client) {
+		c.retryOnEmptyChoices = retry
+	}
+}
+
+// WithMaxTokensFieldName задает поле для ограничения длины ответа:
+// MaxTokensField или MaxCompletionTokensField. По умолчанию поле
+// выбирается автоматически по имени модели (o1, o3, o4, gpt-5 - max_completion_tokens)
+func WithMaxTokensFieldName(name string) Option {
+	return func(c *Client) {
+		c.maxTokensField = name
+	}
+}
+
+// WithDefaultReasoningEffort задает reasoning_effort для запросов, в которых он не указан
+func WithDefaultReasoningEffort(effort string) Option {
+	return func(c *Client) {
+		c.defaultReasoningEffort = effort
+	}
+}
+
+// WithDebugDump включает запись сырых байтов запроса и ответа в w.
+// Тело ответа дублируется по мере чтения, поэтому декодирование не нарушается.
+// Секретные заголовки маскируются (см. WithRedactedHeaders)
+func WithDebugDump(w io.Writer) Option {
+	return func(c *Client) {
+		c.debugDump = w
+	}
+}
+
+// WithTransientBodyMarkers включает повтор запросов, тело ответа которых содержит
+// признаки временной ошибки провайдера (без учета регистра). Без аргументов
+// используются признаки по умолчанию: "overloaded" и "try again"
+func WithTransientBodyMarkers(markers ...string) Option {
+	return func(c *Client) {
+		if len(markers) == 0 {
+			markers = defaultTransientMarkers
+		}
+		c.transientMarkers = markers
+	}
+}
+
+// WithForceHTTP1 принудительно использует HTTP/1.1 - для шлюзов, которые
+// некорректно работают с мультиплексированием HTTP/2.
+// При стриминге каждый поток занимает отдельное соединение
+func WithForceHTTP1() Option {
+	return func(c *Client) {
+		c.transportMods = append(c.transportMods, forceHTTP1)
+	}
+}
+
+// WithForceHTTP2 включает HTTP/2 даже для кастомного транспорта.
+// При стриминге SSE по HTTP/2 события могут приходить с другой периодичностью,
+// так как сервер буферизует DATA-фреймы
+func WithForceHTTP2() Option {
+	return func(c *Client) {
+		c.transportMods = append(c.transportMods, forceHTTP2)
+	}
+}
+
+// WithDefaultPromptCacheKey задает prompt_cache_key для запросов, в которых он не указан.
+// Провайдер использует ключ для кэширования повторяющихся префиксов промпта
+func WithDefaultPromptCacheKey(key string) Option {
+	return func(c *Client) {
+		c.defaultPromptCacheKey = key
+	}
+}
+
+// WithRequestEncoder задает формат тела запроса вместо JSON.
+// Заголовок Content-Type устанавливается в contentType
+func WithRequestEncoder(contentType string, encode RequestEncoder) Option {
+	return func(c *Client) {
+		c.contentType = contentType
+		c.encodeRequest = encode
+	}
+}
+
+// WithResponseDecoder задает декодер тела успешного ответа вместо JSON.
+// Потоковые ответы по-прежнему разбираются как SSE с JSON данными
+func WithResponseDecoder(decode ResponseDecoder) Option {
+	return func(c *Client) {
+		c.decodeResponse = decode
+	}
+}
+
+// WithRetryLogger устанавливает обработчик, вызываемый перед каждым повтором запроса
+func WithRetryLogger(logger RetryLogger) Option {
+	return func(c *Client) {
+		c.retryLogger = logger
+	}
+}
+
+// WithStreamReconnect задает, сколько раз ChatStream переотправит запрос, если
+// соединение оборвалось до получения первого фрагмента. Для идемпотентных запросов
+// (temperature 0, seed) это безопасно; обрыв посреди потока не восстанавливается
+func WithStreamReconnect(max int) Option {
+	return func(c *Client) {
+		c.streamReconnects = max
+	}
+}
+
+// WithModelPrefix направляет запросы к моделям с указанным префиксом на отдельный
+// baseURL с собственным ключом (например, "local-" на локальную Ollama).
+// Выигрывает самый длинный совпавший префикс, остальные модели используют
+// baseURL и ключ из NewClient
+func WithModelPrefix(prefix, baseURL, apiKey string) Option {
+	return func(c *Client) {
+		c.modelRoutes = append(c.modelRoutes, modelRoute{
+			prefix:   prefix,
+			endpoint: endpoint{baseURL: baseURL, apiKey: apiKey},
+		})
+	}
+}
+
+// WithTrimStopSequences включает удаление стоп-последовательностей запроса с конца
+// ответа: одни провайдеры включают их в content, другие нет
+func WithTrimStopSequences(trim bool) Option {
+	return func(c *Client) {
+		c.trimStopSequences = trim
+	}
+}
+
+// WithMaxRequestBytes ограничивает размер сериализованного тела запроса.
+// Запросы большего размера отклоняются до отправки с ошибкой *RequestTooLargeError
+func WithMaxRequestBytes(n int64) Option {
+	return func(c *Client) {
+		c.maxRequestBytes = n
+	}
+}
+
+// WithDefaultServiceTier задает service_tier для запросов, в которых он не указан.
+// Фактически использованный уровень возвращается в ChatResponse.ServiceTier
+func WithDefaultServiceTier(tier string) Option {
+	return func(c *Client) {
+		c.defaultServiceTier = tier
+	}
+}
+
+// WithDefaultTemperature задает temperature для запросов, в которых она не указана
+// (равна 0). Нулевое значение по умолчанию отправляется явно
+func WithDefaultTemperature(temperature float32) Option {
+	return func(c *Client) {
+		c.defaultTemperature = &temperature
+	}
+}
+
+// WithDefaultTopP задает top_p для запросов, в которых он не указан
+func WithDefaultTopP(topP float32) Option {
+	return func(c *Client) {
+		c.defaultTopP = &topP
+	}
+}
+
+// WithDefaultSeed задает seed для запросов без Seed и без seed в контексте
+// (ContextWithSeed)
+func WithDefaultSeed(seed int) Option {
+	return func(c *Client) {
+		c.defaultSeed = &seed
+	}
+}
+
+// WithProfile задает temperature, top_p и seed по умолчанию из готового профиля:
+// ProfileDeterministic, ProfileCreative или ProfileBalanced. Параметры из
+// WithDefaultTemperature, WithDefaultTopP и WithDefaultSeed, указанные после
+// профиля, заменяют его значения
+func WithProfile(profile Profile) Option {
+	return profile.apply
+}
+
+// WithDefaultTopK задает top_k для запросов, в которых он не указан. Параметр
+// поддерживают локальные бэкенды (llama.cpp, Ollama, vLLM), но не OpenAI
+func WithDefaultTopK(topK int) Option {
+	return func(c *Client) {
+		c.defaultTopK = &topK
+	}
+}
+
+// WithStrictFinishReason включает проверку finish_reason: если хотя бы один вариант
+// завершился не по "stop" (length, content_filter), Chat и SimpleRequest возвращают
+// *UnexpectedFinishError вместе с полученным ответом. "tool_calls" считается
+// неожиданным, только если в запросе не заданы Tools
+func WithStrictFinishReason() Option {
+	return func(c *Client) {
+		c.strictFinishReason = true
+	}
+}
+
+// WithAuthScheme задает схему авторизации перед ключом (по умолчанию "Bearer").
+// Пустая схема передает ключ как есть, например для заголовка api-key
+func WithAuthScheme(scheme string) Option {
+	return func(c *Client) {
+		c.authScheme = scheme
+	}
+}
+
+// WithAuthHeader задает имя заголовка, в котором передается ключ (по умолчанию Authorization)
+func WithAuthHeader(name string) Option {
+	return func(c *Client) {
+		c.authHeader = name
+	}
+}
+
+// WithQueryParam добавляет параметр в строку запроса всех запросов к API,
+// например api-version для Azure. Повторный вызов с тем же ключом добавляет еще одно значение
+func WithQueryParam(key, value string) Option {
+	return func(c *Client) {
+		if c.queryParams == nil {
+			c.queryParams = url.Values{}
+		}
+		c.queryParams.Add(key, value)
+	}
+}
+
+// WithStreamTotalTimeout ограничивает время всего потокового ответа, включая
+// подключение и переподключения. При превышении ChatStream возвращает
+// *StreamTimeoutError, отличимую от отмены контекста вызывающего
+func WithStreamTotalTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.streamTotalTimeout = d
+	}
+}
+
+// WithTimeoutPerToken задает дедлайн вызова пропорционально длине ответа:
+// 30 секунд плюс d на каждый токен MaxTokens (или MaxCompletionTokens).
+// Дедлайн применяется, только если у контекста вызова нет своего
+// и запрос ограничивает длину ответа
+func WithTimeoutPerToken(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeoutPerToken = d
+	}
+}
+
+// WithModelFallback задает запасные модели: если модель запроса недоступна
+// (404/400 "model not found" или код model_not_found), Chat повторяет запрос
+// со следующей моделью из списка
+func WithModelFallback(models ...string) Option {
+	return func(c *Client) {
+		c.modelFallbacks = append(c.modelFallbacks, models...)
+	}
+}
+
+// WithDeadlinePropagation включает передачу дедлайна контекста запроса серверу
+// в заголовке header: DeadlineHeaderMilliseconds (по умолчанию, при пустом имени)
+// или DeadlineHeaderGRPC. Так шлюз может прекратить генерацию, результат которой
+// клиенту уже не нужен. Запросы без дедлайна отправляются без заголовка
+func WithDeadlinePropagation(header string) Option {
+	return func(c *Client) {
+		if header == "" {
+			header = DeadlineHeaderMilliseconds
+		}
+		c.deadlineHeader = header
+	}
+}
+
+// WithRedactedHeaders добавляет заголовки, значения которых маскируются во всех
+// отладочных выводах и хуках. Authorization, api-key, x-api-key и заголовок
+// из WithAuthHeader маскируются всегда
+func WithRedactedHeaders(names ...string) Option {
+	return func(c *Client) {
+		for _, name := range names {
+			c.redactedHeaders[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+}
+
+// WithOutboundFilter задает фильтр сообщений, который вызывается перед каждой
+// отправкой Chat и ChatStream после применения остальных настроек запроса.
+// Фильтр может замаскировать содержимое (например, персональные данные)
+// или отклонить запрос, вернув ошибку
+func WithOutboundFilter(filter OutboundFilter) Option {
+	return func(c *Client) {
+		c.outboundFilter = filter
+	}
+}
+
+// WithHTTPTrace подключает httptrace.ClientTrace к каждой попытке запроса,
+// чтобы видеть время DNS, соединения, TLS и первого байта без замены транспорта
+func WithHTTPTrace(trace *httptrace.ClientTrace) Option {
+	return func(c *Client) {
+		c.httpTrace = trace
+	}
+}
+
+// WithLocale задает заголовок Accept-Language для запросов генерации, чтобы
+// провайдеры, которые его учитывают, отвечали на нужном языке (например, "fr" или "de-DE")
+func WithLocale(lang string) Option {
+	return func(c *Client) {
+		c.locale = lang
+	}
+}
+
+// WithSingleflight объединяет одновременные вызовы Chat с одинаковым телом запроса:
+// выполняется один HTTP запрос, и его результат получают все ожидающие вызовы.
+// Объединяются только запросы, в которых явно отправляется нулевая температура
+// (например, через WithDefaultTemperature(0)), остальные отправляются как обычно.
+// Отмена контекста первого вызова прерывает запрос для всех
+func WithSingleflight() Option {
+	return func(c *Client) {
+		c.flights = &flightGroup{}
+	}
+}
+
+// WithTransportWrapper оборачивает транспорт клиента, сохраняя его настройки
+// (например, для метрик или записи запросов на уровне HTTP). Обертки применяются
+// после WithForceHTTP1/WithForceHTTP2 и в порядке указания, так что последняя
+// обертка вызывается первой
+func WithTransportWrapper(wrap func(http.RoundTripper) http.RoundTripper) Option {
+	return func(c *Client) {
+		c.transportWrappers = append(c.transportWrappers, wrap)
+	}
+}
+
+// WithModelDefaults задает параметры по умолчанию для модели model (точное
+// совпадение имени). Они заполняют незаданные поля запроса раньше общих
+// настроек клиента, например WithDefaultReasoningEffort
+func WithModelDefaults(model string, defaults ChatRequestDefaults) Option {
+	return func(c *Client) {
+		if c.modelDefaults == nil {
+			c.modelDefaults = make(map[string]ChatRequestDefaults)
+		}
+		c.modelDefaults[model] = defaults
+	}
+}
+
+// WithChatPathFunc задает функцию, которая вычисляет путь эндпоинта чат-комплишенов
+// (относительно baseURL) по модели запроса, например "/chat/completions/"+model
+// или путь деплоймента Azure. По умолчанию используется /v1/chat/completions
+func WithChatPathFunc(fn func(model string) string) Option {
+	return func(c *Client) {
+		c.chatPathFunc = fn
+	}
+}
+
+// WithUseJSONNumber включает json.Number для чисел при разборе структурированных
+// ответов (RequestWithSchema, RequestWithSchemaVote, ChatStreamSchema) в поля
+// interface{} и map[string]interface{}, чтобы большие целые не теряли точность
+// при преобразовании в float64. Поля с конкретными числовыми типами не затрагиваются
+func WithUseJSONNumber() Option {
+	return func(c *Client) {
+		c.useJSONNumber = true
+	}
+}
+
+// WithBackoffStrategy задает стратегию задержек между повторами.
+// По умолчанию используется ExponentialBackoff
+func WithBackoffStrategy(strategy BackoffStrategy) Option {
+	return func(c *Client) {
+		c.backoffStrategy = strategy
+	}
+}
+
+// WithBackoff задает базовую и максимальную задержку между повторами для всех
+// стратегий. По умолчанию base равен 1 секунде, а max = 0 означает отсутствие ограничения.
+// max ограничивает и задержку из заголовка Retry-After; при max = 0 она
+// ограничена минутой
+func WithBackoff(base, max time.Duration) Option {
+	return func(c *Client) {
+		c.backoffBase = base
+		c.backoffMax = max
+	}
+}
+
+// WithBodyLogSampler записывает в w дамп запроса и ответа в формате WithDebugDump
+// для случайной доли rate (от 0 до 1) запросов. Секретные заголовки маскируются
+func WithBodyLogSampler(rate float64, w io.Writer) Option {
+	return func(c *Client) {
+		c.bodyLogRate = rate
+		c.bodyLogWriter = w
+	}
+}
+
+// WithAfterResponse задает хук, который вызывается сразу после декодирования
+// успешного ответа Chat, до остальных проверок, и может изменить ответ
+// (например, снять обертку шлюза). Ошибка хука прерывает вызов
+func WithAfterResponse(hook func(*ChatResponse) error) Option {
+	return func(c *Client) {
+		c.afterResponse = hook
+	}
+}
+
+// WithConcurrencyPerModel ограничивает число одновременных запросов к каждой модели
+// (после подстановки модели по умолчанию). Модели, которых нет в limits, не ограничены,
+// если не задан общий лимит под ключом DefaultModelLimit. Слот занят, пока не закрыто
+// тело ответа, поэтому потоковый ответ удерживает его до конца чтения
+func WithConcurrencyPerModel(limits map[string]int) Option {
+	return func(c *Client) {
+		c.modelLimiter = newModelLimiter(limits)
+	}
+}
+
+// WithStructuredOutputMode задает способ запроса структурированного вывода в
+// RequestWithSchema, RequestWithSchemaVote и ChatStreamSchema. StructuredOutputAuto
+// выбирает json_schema, json_object или схему в промпте по имени модели
+func WithStructuredOutputMode(mode StructuredOutputMode) Option {
+	return func(c *Client) {
+		c.structuredOutputMode = mode
+	}
+}
+
+// WithCircuitBreaker размыкает предохранитель после failureThreshold подряд неудачных
+// запросов (ошибка транспорта, истечение дедлайна или исчерпание повторов из-за
+// ответов сервера с ошибкой): до истечения cooldown запросы
+// завершаются ошибкой ErrCircuitOpen без обращения к серверу, затем пропускается один
+// пробный запрос. Состояние общее для всех горутин, использующих клиент
+func WithCircuitBreaker(failureThreshold int, cooldown time.Duration) Option {
+	return func(c *Client) {
+		if failureThreshold <= 0 {
+			c.breaker = nil
+			return
+		}
+		c.breaker = newCircuitBreaker(failureThreshold, cooldown)
+	}
+}
+
+// WithMinAvgLogprob отклоняет ответы, у которых средний логарифм вероятности токенов
+// какого-либо варианта ниже threshold. Проверка выполняется только для запросов с
+// Logprobs: true, отклоненный ответ запрашивается повторно в пределах WithMaxRetries,
+// после чего возвращается ошибка, соответствующая ErrLowConfidence
+func WithMinAvgLogprob(threshold float64) Option {
+	return func(c *Client) {
+		c.minAvgLogprob = &threshold
+	}
+}
+
+// WithUsageExtractor задает функцию, извлекающую использование токенов из сырого
+// тела ответа, когда стандартное поле usage пустое (например, провайдер возвращает
+// его под ключом token_usage). Функция возвращает false, если данных нет
+func WithUsageExtractor(extract func(raw json.RawMessage) (Usage, bool)) Option {
+	return func(c *Client) {
+		c.usageExtractor = extract
+	}
+}
+
+// WithMaxToolRounds ограничивает число обращений к модели в ChatWithTools
+// (по умолчанию 10)
+func WithMaxToolRounds(rounds int) Option {
+	return func(c *Client) {
+		c.maxToolRounds = rounds
+	}
+}
+
+// WithToolArgumentsFeedback включает передачу модели ошибок проверки аргументов
+// инструментов в ChatWithTools вместо возврата ToolArgumentsError, чтобы модель
+// могла повторить вызов с исправленными аргументами
+func WithToolArgumentsFeedback() Option {
+	return func(c *Client) {
+		c.toolArgumentsFeedback = true
+	}
+}
+
+// WithGlobalHeaders добавляет заголовки ко всем запросам клиента. Приоритет при
+// совпадении имен: ChatRequest.Headers > ContextWithHeaders > WithGlobalHeaders >
+// заголовки, которые клиент задает сам (Content-Type, авторизация и т.п.)
+func WithGlobalHeaders(headers http.Header) Option {
+	return func(c *Client) {
+		c.globalHeaders = mergeHeaders(c.globalHeaders, headers)
+	}
+}
+
+// WithMetricsHook задает функцию, которая вызывается один раз по завершении каждого
+// логического вызова Chat или ChatStream с его метриками: размеры запроса и ответа,
+// время до первого байта, длительность, число повторов и итоговый статус
+func WithMetricsHook(hook func(RequestMetrics)) Option {
+	return func(c *Client) {
+		c.metricsHook = hook
+	}
+}
+
+// WithHostTransport задает отдельный транспорт для запросов к host (например,
+// "api.openai.com" или "llm.internal:8080"), чтобы у разных провайдеров были свои
+// пулы соединений и таймауты. Запросы к остальным хостам идут через транспорт
+// клиента. WithForceHTTP1/WithForceHTTP2 на rt не влияют, обертки
+// WithTransportWrapper применяются ко всем хостам
+func WithHostTransport(host string, rt http.RoundTripper) Option {
+	return func(c *Client) {
+		if c.hostTransports == nil {
+			c.hostTransports = make(map[string]http.RoundTripper)
+		}
+		c.hostTransports[strings.ToLower(host)] = rt
+	}
+}
+
+// WithOnRetryExhausted задает функцию, которую Chat вызывает перед возвратом ошибки
+// ErrMaxRetriesExceeded (например, для оповещения или записи в dead-letter очередь).
+// Она получает запрос в том виде, в каком он отправлялся, и итоговую ошибку
+func WithOnRetryExhausted(hook func(ctx context.Context, req ChatRequest, lastErr error)) Option {
+	return func(c *Client) {
+		c.onRetryExhausted = hook
+	}
+}
+
+// WithSoftErrorDetector задает функцию, которая проверяет успешный ответ (тело и
+// заголовки) на некритичные ошибки, например {"error": "degraded result"} вместе с
+// пригодными вариантами. Найденная ошибка сохраняется в ChatResponse.SoftError,
+// а ответ возвращается как успешный
+func WithSoftErrorDetector(detect func(*ChatResponse, http.Header) error) Option {
+	return func(c *Client) {
+		c.softErrorDetector = detect
+	}
+}
+
+// WithUsageCallback задает функцию, которая получает usage каждого разобранного
+// ответа Chat (включая ответы, отклоненные последующими проверками, и повторы)
+// вместе с моделью ответа, например для учета расходов
+func WithUsageCallback(callback func(model string, usage Usage)) Option {
+	return func(c *Client) {
+		c.usageCallback = callback
+	}
+}
+
+// WithUsageGracePeriod позволяет Chat дочитать и разобрать уже начавшийся ответ
+// после отмены контекста вызывающим, но не дольше grace, чтобы usage оплаченного
+// ответа попал в WithUsageCallback. Отмена до получения ответа действует сразу.
+// Цена этого - после отмены Chat может вернуть управление позже на время до grace;
+// если ответ успел разобраться, он возвращается без ошибки
+func WithUsageGracePeriod(grace time.Duration) Option {
+	return func(c *Client) {
+		c.usageGrace = grace
+	}
+}
+
+// WithProviderExtras добавляет в тело каждого запроса Chat и ChatStream поля,
+// специфичные для провайдера (например, "safe_prompt": true у Mistral). Значения
+// могут быть любыми сериализуемыми в JSON, включая вложенные объекты. Поля, которые
+// уже есть в запросе, не заменяются. Повторные вызовы дополняют набор полей
+func WithProviderExtras(extras map[string]interface{}) Option {
+	return func(c *Client) {
+		if c.providerExtras == nil {
+			c.providerExtras = make(map[string]interface{}, len(extras))
+		}
+		for key, value := range extras {
+			c.providerExtras[key] = value
+		}
+	}
+}
+
+// WithCodeFenceLanguages ограничивает метки языка блоков кода markdown, которые
+// RequestWithDecoder удаляет вокруг ответа (например, "xml" или "yaml").
+// По умолчанию удаляется блок с любой меткой
+func WithCodeFenceLanguages(languages ...string) Option {
+	return func(c *Client) {
+		c.fenceLanguages = languages
+	}
+}
+
+// WithTemperatureClamp приводит температуру запросов к диапазону [min, max] вместо
+// отправки значения, которое API отклонит. Применяется к Chat, ChatStream и пакетам.
+// Незаданная температура не изменяется. При min > max опция не действует
+func WithTemperatureClamp(min, max float32) Option {
+	return func(c *Client) {
+		if min > max {
+			c.temperatureClamp = nil
+			return
+		}
+		c.temperatureClamp = &[2]float32{min, max}
+	}
+}
+
+// WithTemperatureClampLogger устанавливает обработчик, вызываемый при каждом
+// изменении температуры запроса опцией WithTemperatureClamp
+func WithTemperatureClampLogger(logger TemperatureClampLogger) Option {
+	return func(c *Client) {
+		c.temperatureClampLogger = logger
+	}
+}
+
+// WithCookieJar включает хранилище cookie: cookie из ответов (например, cookie сессии
+// шлюза) отправляются в последующих запросах. При jar == nil создается cookiejar.Jar.
+// Хранилище устанавливается на копию HTTP клиента
+func WithCookieJar(jar http.CookieJar) Option {
+	return func(c *Client) {
+		c.useCookieJar = true
+		c.cookieJar = jar
+	}
+}
+
+// WithResponseHeaderCapture сохраняет последнее значение заголовка ответа name и
+// перед каждым следующим запросом передает его в apply (например, для привязки
+// к узлу шлюза). До первого ответа с заголовком apply не вызывается
+func WithResponseHeaderCapture(name string, apply func(value string, req *http.Request)) Option {
+	return func(c *Client) {
+		c.headerCaptures = append(c.headerCaptures, &headerCapture{name: name, apply: apply})
+	}
+}
+
+// WithSchemaExampleInjection добавляет в системный промпт RequestWithSchema и других
+// методов со схемой пример ожидаемого ответа, построенный как в GenerateExample
+func WithSchemaExampleInjection() Option {
+	return func(c *Client) {
+		c.schemaExampleInjection = true
+	}
+}
+
+// WithValidateModel проверяет в Chat и ChatStream, что модель запроса (или клиента,
+// если в запросе она не задана) есть в ListModels, и возвращает ErrModelNotAvailable
+// вместо ошибки запроса к несуществующей модели. Список моделей запрашивается при
+// первом вызове и кэшируется отдельно для каждого эндпоинта WithModelPrefix
+func WithValidateModel() Option {
+	return func(c *Client) {
+		c.modelCheck = &modelCheck{}
+	}
+}
+
+// WithStrictResponseDecoding проверяет, что успешный ответ - JSON объект с полем
+// choices, и иначе возвращает *ResponseShapeError с полями и телом ответа вместо
+// ErrNoChoices (например, для ошибки, пришедшей со статусом 200).
+// Неизвестные поля не отклоняются: провайдеры добавляют свои поля в ответы
+func WithStrictResponseDecoding() Option {
+	return func(c *Client) {
+		c.strictResponseDecoding = true
+	}
+}
+
+// WithFairScheduler ограничивает общую частоту запросов клиента rps запросами в
+// секунду с запасом burst и при конкуренции пропускает ожидающие запросы по очереди
+// моделей, чтобы поток запросов одной модели не задерживал запросы другой.
+// Учитывается каждая попытка, включая повторы. При rps <= 0 опция не действует
+func WithFairScheduler(rps float64, burst int) Option {
+	return func(c *Client) {
+		if rps <= 0 {
+			c.scheduler = nil
+			return
+		}
+		c.scheduler = newFairScheduler(rps, burst)
+	}
+}
+
+// WithResponseRecorder сохраняет каждый ответ сервера (статус, заголовки и тело)
+// в файл каталога dir, названный по хэшу метода, пути и тела запроса. Вместе с
+// WithResponseReplay позволяет собрать набор фикстур для офлайн-тестов.
+// Потоковый ответ передается вызывающему только после записи целиком
+func WithResponseRecorder(dir string) Option {
+	return WithTransportWrapper(func(next http.RoundTripper) http.RoundTripper {
+		return &recordTransport{dir: dir, next: next}
+	})
+}
+
+// WithResponseReplay отдает ответ из каталога dir (см. WithResponseRecorder), если
+// для запроса есть запись, и обращается к сети, если записи нет
+func WithResponseReplay(dir string) Option {
+	return WithTransportWrapper(func(next http.RoundTripper) http.RoundTripper {
+		return &recordTransport{dir: dir, replay: true, next: next}
+	})
+}
+
+// WithRetryErrorParsing разбирает тела ответов с повторяемым статусом (429, 5xx)
+// парсером ошибок (по умолчанию в *APIError) вместо *StatusError с началом тела.
+// Тогда ошибка ErrMaxRetriesExceeded содержит диагностику провайдера из последнего
+// ответа, доступную через errors.As
+func WithRetryErrorParsing() Option {
+	return func(c *Client) {
+		c.parseRetryErrors = true
+	}
+}
+
+// WithStreamBufferSize задает размер буфера чтения потоковых ответов в байтах
+// (по умолчанию 64 КБ, минимум 16). Больший буфер уменьшает число чтений при
+// больших фрагментах, меньший экономит память. Длина события буфером не
+// ограничивается: длинные строки собираются по частям
+func WithStreamBufferSize(bytes int) Option {
+	return func(c *Client) {
+		c.streamBufferSize = bytes
+	}
+}
+
+// WithExpectContinue отправляет запросы с заголовком "Expect: 100-continue" и
+// ждет ответа сервера до timeout перед передачей тела (Transport.ExpectContinueTimeout).
+// Сервер может отклонить большой запрос (размер, авторизация) до загрузки тела.
+// Добавляет задержку на каждый запрос, поэтому по умолчанию выключено.
+// Таймаут применяется к копии *http.Transport; при timeout <= 0 опция не действует
+func WithExpectContinue(timeout time.Duration) Option {
+	return func(c *Client) {
+		if timeout <= 0 {
+			return
+		}
+		c.expectContinue = true
+		c.transportMods = append(c.transportMods, func(t *http.Transport) {
+			t.ExpectContinueTimeout = timeout
+		})
+	}
+}
+
+// WithRequestCompression сжимает в gzip тела запросов от 8 КБ и добавляет заголовок
+// "Content-Encoding: gzip". Если сервер отвечает на сжатый запрос статусом 415,
+// запрос сразу повторяется без сжатия, и дальше клиент тела не сжимает. Отклоненная
+// попытка видна в дампах и учитывается в метриках как повтор
+func WithRequestCompression() Option {
+	return func(c *Client) {
+		c.compression = &requestCompression{threshold: defaultCompressionThreshold}
+	}
+}
+
+// WithAdaptiveTimeout ограничивает вызовы Chat дедлайном, равным factor средних
+// длительностей последних успешных вызовов той же модели (скользящее среднее),
+// но не меньше секунды. Дедлайн применяется после трех вызовов модели и только
+// если у контекста нет своего дедлайна. Дедлайн ограничивает весь вызов, включая
+// повторы и паузы между ними, поэтому медленная попытка может не оставить времени
+// на повтор. Вызов, прерванный этим дедлайном, увеличивает среднее.
+// При factor <= 0 опция не действует
+func WithAdaptiveTimeout(factor float64) Option {
+	return func(c *Client) {
+		if factor <= 0 {
+			c.adaptiveTimeout = nil
+			return
+		}
+		c.adaptiveTimeout = newAdaptiveTimeout(factor)
+	}
+}
+
+// WithHistoryTruncation ограничивает историю запроса maxMessages сообщениями, удаляя
+// перед отправкой самые старые. Системные сообщения и последнее сообщение
+// пользователя со следующими за ним сохраняются (см. TruncateMessages)
+func WithHistoryTruncation(maxMessages int) Option {
+	return func(c *Client) {
+		c.maxHistoryMessages = maxMessages
+	}
+}
+
+// WithTokenBudget ограничивает историю запроса maxTokens токенами по оценке tok
+// (nil - EstimateTokens), удаляя перед отправкой самые старые сообщения
+// (см. TruncateMessages)
+func WithTokenBudget(maxTokens int, tok Tokenizer) Option {
+	return func(c *Client) {
+		c.tokenBudget = maxTokens
+		c.tokenizer = tok
+	}
+}
@@ -0,0 +1,36 @@
+package llmclient
+
+// Profile - набор параметров генерации по умолчанию (см. WithProfile)
+type Profile int
+
+const (
+	// ProfileBalanced - temperature 0.7, top_p и seed не задаются
+	ProfileBalanced Profile = iota
+	// ProfileDeterministic - temperature 0 и фиксированный seed ProfileSeed
+	ProfileDeterministic
+	// ProfileCreative - temperature 0.9 и top_p 0.95
+	ProfileCreative
+)
+
+// ProfileSeed - seed, который задает ProfileDeterministic
+const ProfileSeed = 42
+
+// apply задает клиенту параметры по умолчанию профиля, сбрасывая остальные
+func (p Profile) apply(c *Client) {
+	c.defaultTopP = nil
+	c.defaultSeed = nil
+
+	switch p {
+	case ProfileDeterministic:
+		temperature, seed := float32(0), ProfileSeed
+		c.defaultTemperature = &temperature
+		c.defaultSeed = &seed
+	case ProfileCreative:
+		temperature, topP := float32(0.9), float32(0.95)
+		c.defaultTemperature = &temperature
+		c.defaultTopP = &topP
+	default:
+		temperature := float32(0.7)
+		c.defaultTemperature = &temperature
+	}
+}
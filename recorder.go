@@ -0,0 +1,111 @@
+package llmclient
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+)
+
+// fixtureExt - расширение файлов записанных ответов
+const fixtureExt = ".http"
+
+// recordTransport записывает ответы (WithResponseRecorder) или отдает записанные
+// ответы вместо обращения к сети (WithResponseReplay). Файл ответа называется по
+// SHA-256 метода, пути с параметрами и тела запроса; заголовки (в том числе ключ API)
+// в ключ не входят
+type recordTransport struct {
+	dir    string
+	replay bool
+	next   http.RoundTripper
+}
+
+// RoundTrip реализует http.RoundTripper
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	out, key, err := fixtureRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(t.dir, key+fixtureExt)
+
+	if t.replay {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			return t.next.RoundTrip(out)
+		}
+		if out.Body != nil {
+			out.Body.Close()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture: %w", err)
+		}
+		return http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+	}
+
+	resp, err := t.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+
+	// DumpResponse читает тело целиком и заменяет его копией в памяти
+	data, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to record response: %w", err)
+	}
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to record response: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to record response: %w", err)
+	}
+
+	return resp, nil
+}
+
+// fixtureRequest вычисляет ключ записи запроса и возвращает копию req для отправки,
+// не изменяя сам req. Тело для ключа берется из GetBody, а без него читается
+// и подставляется в копию
+func fixtureRequest(req *http.Request) (*http.Request, string, error) {
+	out := req.Clone(req.Context())
+
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if req.GetBody != nil {
+			body, err = readGetBody(req)
+		} else {
+			body, err = io.ReadAll(req.Body)
+			req.Body.Close()
+			out.Body = io.NopCloser(bytes.NewReader(body))
+			out.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(body)), nil
+			}
+		}
+		if err != nil {
+			req.Body.Close()
+			return nil, "", fmt.Errorf("failed to read request body: %w", err)
+		}
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s %s\n", req.Method, req.URL.RequestURI())
+	hash.Write(body)
+	return out, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// readGetBody читает копию тела запроса, полученную через GetBody
+func readGetBody(req *http.Request) ([]byte, error) {
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
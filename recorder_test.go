@@ -0,0 +1,80 @@
+package llmclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClient_ResponseRecorderReplay(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"recorded"},"finish_reason":"stop"}]}`))
+	}))
+
+	dir := t.TempDir()
+	recorder := NewClient(server.URL, "test-key", "model", WithResponseRecorder(dir))
+	if content, err := recorder.SimpleRequest(context.Background(), "sys", "user"); err != nil || content != "recorded" {
+		t.Fatalf("Unexpected result: %q, %v", content, err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.http")); len(files) != 1 {
+		t.Fatalf("Expected 1 fixture, got %v", files)
+	}
+
+	replay := NewClient(server.URL, "other-key", "model", WithResponseReplay(dir))
+	if content, err := replay.SimpleRequest(context.Background(), "sys", "user"); err != nil || content != "recorded" {
+		t.Fatalf("Unexpected replayed result: %q, %v", content, err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected replay without network, got %d calls", calls)
+	}
+
+	if _, err := replay.SimpleRequest(context.Background(), "sys", "another prompt"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected unrecorded request to reach the server, got %d calls", calls)
+	}
+
+	server.Close()
+	if _, err := replay.SimpleRequest(context.Background(), "sys", "user"); err != nil {
+		t.Errorf("Expected replay to work offline, got %v", err)
+	}
+}
+
+func TestRecordTransport_KeepsCallerRequest(t *testing.T) {
+	var got string
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		got = string(body)
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("{}")), Request: req}, nil
+	})
+	transport := &recordTransport{dir: t.TempDir(), next: next}
+
+	for _, withGetBody := range []bool{true, false} {
+		req, err := http.NewRequest(http.MethodPost, "http://localhost/v1/chat/completions", strings.NewReader(`{"model":"m"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !withGetBody {
+			req.GetBody = nil
+		}
+		body := req.Body
+
+		if _, err := transport.RoundTrip(req); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if req.Body != body {
+			t.Errorf("Expected caller request body to be left unchanged (GetBody %v)", withGetBody)
+		}
+		if got != `{"model":"m"}` {
+			t.Errorf("Expected full body to reach next transport, got %q", got)
+		}
+	}
+}
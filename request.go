@@ -0,0 +1,300 @@
+package llmclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Имена полей запроса для ограничения длины ответа
+const (
+	MaxTokensField           = "max_tokens"
+	MaxCompletionTokensField = "max_completion_tokens"
+)
+
+// completionTokensModelPrefixes - префиксы моделей, которые принимают только max_completion_tokens
+var completionTokensModelPrefixes = []string{"o1", "o3", "o4", "gpt-5"}
+
+// maxStopSequences - максимальное количество стоп-последовательностей, принимаемое API
+const maxStopSequences = 4
+
+// timeoutPerTokenBase - базовая часть дедлайна WithTimeoutPerToken,
+// покрывающая установку соединения и обработку промпта
+const timeoutPerTokenBase = 30 * time.Second
+
+// prepareRequest применяет настройки клиента к запросу перед отправкой
+func (c *Client) prepareRequest(ctx context.Context, req ChatRequest) ChatRequest {
+	if req.Model == "" {
+		req.Model = c.model
+	}
+
+	if defaults, ok := c.modelDefaults[req.Model]; ok {
+		defaults.apply(&req)
+	}
+
+	if req.Seed == nil {
+		if seed, ok := seedFromContext(ctx); ok {
+			req.Seed = &seed
+		} else if c.defaultSeed != nil {
+			seed := *c.defaultSeed
+			req.Seed = &seed
+		}
+	}
+
+	if req.Temperature == 0 && c.defaultTemperature != nil {
+		req.Temperature = *c.defaultTemperature
+		req.explicitTemperature = req.Temperature == 0
+	}
+
+	if req.TopP == 0 && c.defaultTopP != nil {
+		req.TopP = *c.defaultTopP
+	}
+
+	c.clampTemperature(&req)
+
+	if c.singleSystemMessage {
+		req.Messages = collapseSystemMessages(req.Messages)
+	}
+
+	req.Messages = c.truncateRequestHistory(req.Messages)
+
+	if len(c.defaultStop) > 0 && (len(req.Stop) == 0 || c.defaultStopAlways) {
+		req.Stop = mergeStops(req.Stop, c.defaultStop)
+	}
+
+	if req.ReasoningEffort == "" {
+		req.ReasoningEffort = c.defaultReasoningEffort
+	}
+
+	if req.PromptCacheKey == "" {
+		req.PromptCacheKey = c.defaultPromptCacheKey
+	}
+
+	if req.ServiceTier == "" {
+		req.ServiceTier = c.defaultServiceTier
+	}
+
+	if req.TopK == nil && c.defaultTopK != nil {
+		topK := *c.defaultTopK
+		req.TopK = &topK
+	}
+
+	c.normalizeMaxTokens(&req)
+
+	req.extras = c.providerExtras
+
+	return req
+}
+
+// clampTemperature приводит заданную температуру запроса к диапазону WithTemperatureClamp.
+// Незаданная (нулевая) температура не изменяется
+func (c *Client) clampTemperature(req *ChatRequest) {
+	if c.temperatureClamp == nil || (req.Temperature == 0 && !req.explicitTemperature) {
+		return
+	}
+
+	clamped := min(max(req.Temperature, c.temperatureClamp[0]), c.temperatureClamp[1])
+	if clamped == req.Temperature {
+		return
+	}
+
+	if c.temperatureClampLogger != nil {
+		c.temperatureClampLogger(req.Model, req.Temperature, clamped)
+	}
+	req.Temperature = clamped
+	req.explicitTemperature = clamped == 0
+}
+
+// TemperatureClampLogger вызывается, когда WithTemperatureClamp изменяет температуру запроса
+type TemperatureClampLogger func(model string, requested, clamped float32)
+
+// ChatRequestDefaults - параметры по умолчанию для модели (см. WithModelDefaults).
+// Применяются только к незаданным (нулевым) полям запроса
+type ChatRequestDefaults struct {
+	Temperature         float32
+	TopP                float32
+	MaxTokens           int
+	MaxCompletionTokens int
+	ReasoningEffort     string
+}
+
+// apply заполняет незаданные поля запроса значениями по умолчанию.
+// Лимит длины ответа подставляется, только если не задано ни одно из полей лимита
+func (d ChatRequestDefaults) apply(req *ChatRequest) {
+	if req.Temperature == 0 {
+		req.Temperature = d.Temperature
+	}
+	if req.TopP == 0 {
+		req.TopP = d.TopP
+	}
+	if req.MaxTokens == 0 && req.MaxCompletionTokens == 0 {
+		req.MaxTokens = d.MaxTokens
+		req.MaxCompletionTokens = d.MaxCompletionTokens
+	}
+	if req.ReasoningEffort == "" {
+		req.ReasoningEffort = d.ReasoningEffort
+	}
+}
+
+// normalizeMaxTokens оставляет в запросе только одно из полей max_tokens и
+// max_completion_tokens: отправка обоих приводит к ошибке 400
+func (c *Client) normalizeMaxTokens(req *ChatRequest) {
+	limit := req.MaxCompletionTokens
+	if limit == 0 {
+		limit = req.MaxTokens
+	}
+	if limit == 0 {
+		return
+	}
+
+	field := c.maxTokensField
+	if field == "" {
+		switch {
+		case usesMaxCompletionTokens(req.Model), req.MaxCompletionTokens != 0:
+			field = MaxCompletionTokensField
+		default:
+			field = MaxTokensField
+		}
+	}
+
+	if field == MaxCompletionTokensField {
+		req.MaxTokens, req.MaxCompletionTokens = 0, limit
+	} else {
+		req.MaxTokens, req.MaxCompletionTokens = limit, 0
+	}
+}
+
+// usesMaxCompletionTokens определяет по имени модели, требует ли она max_completion_tokens.
+// Префикс провайдера вида "openai/" не учитывается
+func usesMaxCompletionTokens(model string) bool {
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+
+	for _, prefix := range completionTokensModelPrefixes {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// OutboundFilter проверяет или изменяет сообщения перед отправкой.
+// Ошибка фильтра прерывает вызов
+type OutboundFilter func(messages []Message) ([]Message, error)
+
+// filterOutbound применяет WithOutboundFilter к копии сообщений запроса,
+// чтобы фильтр не изменял срез вызывающего
+func (c *Client) filterOutbound(req ChatRequest) (ChatRequest, error) {
+	if c.outboundFilter == nil {
+		return req, nil
+	}
+
+	messages, err := c.outboundFilter(append([]Message(nil), req.Messages...))
+	if err != nil {
+		return req, fmt.Errorf("outbound filter: %w", err)
+	}
+	req.Messages = messages
+
+	return req, nil
+}
+
+// withTokenDeadline ограничивает контекст вызова дедлайном base + токены*perToken,
+// если задан WithTimeoutPerToken, запрос ограничивает длину ответа, а у контекста
+// вызывающего callerCtx нет своего дедлайна
+func (c *Client) withTokenDeadline(ctx, callerCtx context.Context, req ChatRequest) (context.Context, context.CancelFunc) {
+	tokens := req.MaxTokens
+	if tokens == 0 {
+		tokens = req.MaxCompletionTokens
+	}
+
+	if c.timeoutPerToken <= 0 || tokens <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := callerCtx.Deadline(); ok {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, timeoutPerTokenBase+time.Duration(tokens)*c.timeoutPerToken)
+}
+
+// Заголовки передачи дедлайна клиента серверу
+const (
+	DeadlineHeaderMilliseconds = "X-Request-Timeout-Ms"
+	DeadlineHeaderGRPC         = "grpc-timeout"
+)
+
+// setDeadlineHeader передает оставшееся до дедлайна контекста (или вызывающего,
+// если он ближе) время в заголовке name в миллисекундах с округлением вверх.
+// Для grpc-timeout используется формат gRPC ("1500m")
+func setDeadlineHeader(req *http.Request, name string) {
+	deadline, ok := deadlineFromContext(req.Context())
+	if !ok {
+		return
+	}
+
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return
+	}
+
+	ms := int64((remaining + time.Millisecond - 1) / time.Millisecond)
+	value := strconv.FormatInt(ms, 10)
+	if strings.EqualFold(name, DeadlineHeaderGRPC) {
+		value += "m"
+	}
+
+	req.Header.Set(name, value)
+}
+
+// encodeBody сериализует запрос и проверяет ограничение WithMaxRequestBytes
+func (c *Client) encodeBody(req interface{}) ([]byte, error) {
+	body, err := c.encodeRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	if c.maxRequestBytes > 0 && int64(len(body)) > c.maxRequestBytes {
+		return nil, &RequestTooLargeError{Size: int64(len(body)), Limit: c.maxRequestBytes}
+	}
+
+	return body, nil
+}
+
+// mergeStops объединяет стоп-последовательности запроса и значения по умолчанию
+// без дубликатов. Последовательности запроса имеют приоритет, результат
+// ограничен maxStopSequences элементами
+func mergeStops(stops, defaults []string) []string {
+	result := make([]string, 0, maxStopSequences)
+	seen := make(map[string]bool, maxStopSequences)
+
+	for _, list := range [][]string{stops, defaults} {
+		for _, stop := range list {
+			if stop == "" || seen[stop] {
+				continue
+			}
+			if len(result) == maxStopSequences {
+				return result
+			}
+			seen[stop] = true
+			result = append(result, stop)
+		}
+	}
+
+	return result
+}
+
+// trimStopSuffix удаляет стоп-последовательность с конца content, если провайдер
+// включил ее в ответ
+func trimStopSuffix(content string, stops []string) string {
+	for _, stop := range stops {
+		if stop != "" && strings.HasSuffix(content, stop) {
+			return strings.TrimSuffix(content, stop)
+		}
+	}
+	return content
+}
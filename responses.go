@@ -0,0 +1,255 @@
+package llmclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// responsesPath - путь эндпоинта Responses API относительно baseURL
+const responsesPath = "/v1/responses"
+
+// Типы элементов Responses API
+const (
+	ResponseItemMessage            = "message"
+	ResponseItemFunctionCall       = "function_call"
+	ResponseItemFunctionCallOutput = "function_call_output"
+	ResponseItemReasoning          = "reasoning"
+	ResponseContentOutputText      = "output_text"
+)
+
+// ResponsesRequest представляет запрос к Responses API (/v1/responses)
+type ResponsesRequest struct {
+	Model              string              `json:"model"`
+	Input              []ResponseInputItem `json:"input"`
+	Instructions       string              `json:"instructions,omitempty"`
+	Tools              []ResponsesTool     `json:"tools,omitempty"`
+	Temperature        float32             `json:"temperature,omitempty"`
+	TopP               float32             `json:"top_p,omitempty"`
+	MaxOutputTokens    int                 `json:"max_output_tokens,omitempty"`
+	PreviousResponseID string              `json:"previous_response_id,omitempty"`
+	Store              *bool               `json:"store,omitempty"`
+	Reasoning          *ResponsesReasoning `json:"reasoning,omitempty"`
+	PromptCacheKey     string              `json:"prompt_cache_key,omitempty"`
+	ServiceTier        string              `json:"service_tier,omitempty"`
+}
+
+// ResponsesReasoning задает параметры рассуждений reasoning-моделей
+type ResponsesReasoning struct {
+	Effort string `json:"effort,omitempty"`
+}
+
+// ResponsesTool описывает инструмент Responses API: функцию (Type "function")
+// или встроенный инструмент провайдера (например, "web_search")
+type ResponsesTool struct {
+	Type        string                 `json:"type"`
+	Name        string                 `json:"name,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// ResponseInputItem представляет элемент input: сообщение, вызов функции
+// или результат вызова функции
+type ResponseInputItem struct {
+	Type      string `json:"type,omitempty"`
+	Role      string `json:"role,omitempty"`
+	Content   string `json:"content,omitempty"`
+	CallID    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	Output    string `json:"output,omitempty"`
+}
+
+// ResponseOutputItem представляет элемент output ответа
+type ResponseOutputItem struct {
+	Type      string            `json:"type"`
+	ID        string            `json:"id,omitempty"`
+	Role      string            `json:"role,omitempty"`
+	Status    string            `json:"status,omitempty"`
+	Content   []ResponseContent `json:"content,omitempty"`
+	CallID    string            `json:"call_id,omitempty"`
+	Name      string            `json:"name,omitempty"`
+	Arguments string            `json:"arguments,omitempty"`
+}
+
+// ResponseContent представляет часть содержимого сообщения в output
+type ResponseContent struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+}
+
+// ResponsesUsage представляет информацию об использовании токенов в Responses API
+type ResponsesUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// ResponsesError описывает ошибку генерации, возвращенную в теле успешного ответа
+type ResponsesError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ResponsesResponse представляет ответ Responses API
+type ResponsesResponse struct {
+	ID          string               `json:"id"`
+	Model       string               `json:"model"`
+	Status      string               `json:"status"`
+	Output      []ResponseOutputItem `json:"output"`
+	Usage       ResponsesUsage       `json:"usage"`
+	Error       *ResponsesError      `json:"error,omitempty"`
+	ServiceTier string               `json:"service_tier,omitempty"`
+}
+
+// OutputText возвращает объединенный текст всех сообщений output
+func (r ResponsesResponse) OutputText() string {
+	var text strings.Builder
+	for _, item := range r.Output {
+		if item.Type != ResponseItemMessage {
+			continue
+		}
+		for _, content := range item.Content {
+			if content.Type == ResponseContentOutputText {
+				text.WriteString(content.Text)
+			}
+		}
+	}
+	return text.String()
+}
+
+// AssistantMessage собирает output в одно сообщение ассистента: текст сообщений
+// и вызовы функций как ToolCalls. Второе значение равно false, если в output
+// нет ни текста, ни вызовов функций
+func (r ResponsesResponse) AssistantMessage() (Message, bool) {
+	msg := Message{Role: "assistant", Content: r.OutputText()}
+
+	for _, item := range r.Output {
+		if item.Type != ResponseItemFunctionCall {
+			continue
+		}
+		msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+			ID:       item.CallID,
+			Type:     "function",
+			Function: FunctionCall{Name: item.Name, Arguments: item.Arguments},
+		})
+	}
+
+	return msg, msg.Content != "" || len(msg.ToolCalls) > 0
+}
+
+// MessagesToResponsesInput преобразует историю чата в элементы input Responses API.
+// Вызовы инструментов ассистента становятся элементами function_call,
+// сообщения с ролью tool - элементами function_call_output
+func MessagesToResponsesInput(messages []Message) []ResponseInputItem {
+	input := make([]ResponseInputItem, 0, len(messages))
+
+	for _, msg := range messages {
+		switch {
+		case msg.Role == "tool":
+			input = append(input, ResponseInputItem{
+				Type:   ResponseItemFunctionCallOutput,
+				CallID: msg.ToolCallID,
+				Output: msg.Content,
+			})
+		case len(msg.ToolCalls) > 0:
+			if msg.Content != "" {
+				input = append(input, ResponseInputItem{Role: msg.Role, Content: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				input = append(input, ResponseInputItem{
+					Type:      ResponseItemFunctionCall,
+					CallID:    call.ID,
+					Name:      call.Function.Name,
+					Arguments: call.Function.Arguments,
+				})
+			}
+		default:
+			input = append(input, ResponseInputItem{Role: msg.Role, Content: msg.Content})
+		}
+	}
+
+	return input
+}
+
+// ToolsToResponsesTools преобразует инструменты чата в инструменты Responses API
+func ToolsToResponsesTools(tools []Tool) []ResponsesTool {
+	result := make([]ResponsesTool, 0, len(tools))
+	for _, tool := range tools {
+		result = append(result, ResponsesTool{
+			Type:        tool.Type,
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			Parameters:  tool.Function.Parameters,
+		})
+	}
+	return result
+}
+
+// Responses выполняет запрос к Responses API. Повторы, авторизация и маршрутизация
+// по модели работают так же, как для Chat
+func (c *Client) Responses(ctx context.Context, req ResponsesRequest) (ResponsesResponse, error) {
+	var resp ResponsesResponse
+
+	req = c.prepareResponsesRequest(req)
+
+	body, err := c.encodeBody(req)
+	if err != nil {
+		return resp, err
+	}
+
+	err = c.execute(ctx, req.Model, responsesPath, body, func(apiResp *http.Response) error {
+		var err error
+		resp, err = c.parseResponsesResponse(apiResp)
+		return err
+	})
+
+	return resp, err
+}
+
+// prepareResponsesRequest применяет к запросу настройки клиента по умолчанию
+func (c *Client) prepareResponsesRequest(req ResponsesRequest) ResponsesRequest {
+	if req.Model == "" {
+		req.Model = c.model
+	}
+
+	if req.Reasoning == nil && c.defaultReasoningEffort != "" {
+		req.Reasoning = &ResponsesReasoning{Effort: c.defaultReasoningEffort}
+	}
+
+	if req.PromptCacheKey == "" {
+		req.PromptCacheKey = c.defaultPromptCacheKey
+	}
+
+	if req.ServiceTier == "" {
+		req.ServiceTier = c.defaultServiceTier
+	}
+
+	return req
+}
+
+// parseResponsesResponse парсит HTTP ответ Responses API
+func (c *Client) parseResponsesResponse(resp *http.Response) (ResponsesResponse, error) {
+	var result ResponsesResponse
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return result, c.errorParser(resp.StatusCode, body)
+	}
+
+	if err := c.decodeResponse(resp.Body, &result); err != nil {
+		return result, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if result.Error != nil {
+		return result, &APIError{
+			StatusCode: resp.StatusCode,
+			Code:       result.Error.Code,
+			Message:    result.Error.Message,
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,128 @@
+package llmclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_Responses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/responses" {
+			t.Errorf("Expected path /v1/responses, got %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			t.Errorf("Unexpected Authorization header: %s", r.Header.Get("Authorization"))
+		}
+
+		var req ResponsesRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != "gpt-4o" {
+			t.Errorf("Expected default model, got %s", req.Model)
+		}
+		if len(req.Input) != 1 || req.Input[0].Role != "user" {
+			t.Errorf("Unexpected input: %+v", req.Input)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"id": "resp_1",
+			"status": "completed",
+			"output": [
+				{"type": "reasoning", "id": "rs_1"},
+				{"type": "message", "role": "assistant", "content": [{"type": "output_text", "text": "Hello"}]},
+				{"type": "function_call", "call_id": "call_1", "name": "lookup", "arguments": "{}"}
+			],
+			"usage": {"input_tokens": 5, "output_tokens": 3, "total_tokens": 8}
+		}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "gpt-4o")
+	resp, err := client.Responses(context.Background(), ResponsesRequest{
+		Input: MessagesToResponsesInput([]Message{{Role: "user", Content: "Hi"}}),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if resp.OutputText() != "Hello" {
+		t.Errorf("Expected output text 'Hello', got %s", resp.OutputText())
+	}
+	if resp.Usage.TotalTokens != 8 {
+		t.Errorf("Expected 8 total tokens, got %d", resp.Usage.TotalTokens)
+	}
+
+	msg, ok := resp.AssistantMessage()
+	if !ok || len(msg.ToolCalls) != 1 || msg.ToolCalls[0].ID != "call_1" {
+		t.Errorf("Unexpected assistant message: %+v", msg)
+	}
+}
+
+func TestClient_Responses_PromptCacheKey(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ResponsesRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		got = req.PromptCacheKey
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"resp_1","status":"completed","output":[]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "gpt-4o", WithDefaultPromptCacheKey("tenant-1"))
+	req := ResponsesRequest{Input: MessagesToResponsesInput([]Message{{Role: "user", Content: "Hi"}})}
+
+	if _, err := client.Responses(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got != "tenant-1" {
+		t.Errorf("Expected default prompt_cache_key, got %q", got)
+	}
+
+	req.PromptCacheKey = "session-42"
+	if _, err := client.Responses(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got != "session-42" {
+		t.Errorf("Expected request prompt_cache_key to be kept, got %q", got)
+	}
+}
+
+func TestClient_Responses_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"resp_1","status":"failed","error":{"code":"server_error","message":"boom"}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "gpt-4o")
+	_, err := client.Responses(context.Background(), ResponsesRequest{})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "server_error" {
+		t.Errorf("Expected APIError with code server_error, got %v", err)
+	}
+}
+
+func TestMessagesToResponsesInput(t *testing.T) {
+	input := MessagesToResponsesInput([]Message{
+		{Role: "user", Content: "Weather?"},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_1", Type: "function", Function: FunctionCall{Name: "weather", Arguments: `{"city":"Oslo"}`}}}},
+		{Role: "tool", ToolCallID: "call_1", Content: "sunny"},
+	})
+
+	if len(input) != 3 {
+		t.Fatalf("Expected 3 input items, got %d: %+v", len(input), input)
+	}
+	if input[1].Type != ResponseItemFunctionCall || input[1].Name != "weather" {
+		t.Errorf("Expected function_call item, got %+v", input[1])
+	}
+	if input[2].Type != ResponseItemFunctionCallOutput || input[2].CallID != "call_1" || input[2].Output != "sunny" {
+		t.Errorf("Expected function_call_output item, got %+v", input[2])
+	}
+}
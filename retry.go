@@ -1,23 +1,210 @@
 package llmclient
 
 import (
+	"bytes"
+	"encoding/json"
+	"io"
 	"math"
+	"math/rand/v2"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// transientBodyPeekLimit - сколько байт тела ответа просматривается
+// в поисках признаков временной ошибки
+const transientBodyPeekLimit = 4096
+
+// defaultTransientMarkers - признаки временных ошибок провайдеров по умолчанию
+var defaultTransientMarkers = []string{"overloaded", "try again"}
+
 // shouldRetry определяет, следует ли повторить запрос
-func shouldRetry(err error, resp *http.Response) bool {
+func (c *Client) shouldRetry(err error, resp *http.Response) bool {
 	if err != nil {
 		return true
 	}
 	if resp.StatusCode >= 500 || resp.StatusCode == 429 {
 		return true
 	}
+	if len(c.transientMarkers) > 0 {
+		return hasTransientMarker(resp, c.transientMarkers)
+	}
+	return false
+}
+
+// hasTransientMarker просматривает начало тела ответа и ищет в нем признаки
+// временной ошибки. Тело подменяется так, что его можно прочитать целиком заново.
+// В успешных ответах признаки ищутся только в объекте "error" тела, которое
+// целиком уместилось в просматриваемый префикс, чтобы не реагировать на текст
+// самой модели
+func hasTransientMarker(resp *http.Response, markers []string) bool {
+	if isEventStream(resp) {
+		return false
+	}
+
+	prefix, _ := io.ReadAll(io.LimitReader(resp.Body, transientBodyPeekLimit))
+	resp.Body = &peekedBody{
+		Reader: io.MultiReader(bytes.NewReader(prefix), resp.Body),
+		Closer: resp.Body,
+	}
+
+	if resp.StatusCode < 300 {
+		var ok bool
+		if prefix, ok = errorEnvelope(prefix); !ok {
+			return false
+		}
+	}
+
+	lower := bytes.ToLower(prefix)
+	for _, marker := range markers {
+		if bytes.Contains(lower, []byte(strings.ToLower(marker))) {
+			return true
+		}
+	}
+
 	return false
 }
 
-// backoff вычисляет задержку для повторного запроса с экспоненциальным backoff
-func backoff(attempt int) time.Duration {
-	return time.Duration(math.Pow(2, float64(attempt))) * time.Second
-}
\ No newline at end of file
+// errorEnvelope возвращает объект "error" тела успешного ответа. Второе значение
+// равно false, если тело не является JSON объектом (в том числе обрезано пределом
+// просмотра) или поле error отсутствует либо не является объектом (например, null)
+func errorEnvelope(body []byte) ([]byte, bool) {
+	var envelope struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, false
+	}
+
+	errObj := bytes.TrimSpace(envelope.Error)
+	if len(errObj) == 0 || errObj[0] != '{' {
+		return nil, false
+	}
+	return errObj, true
+}
+
+// peekedBody возвращает уже прочитанный префикс тела, а затем его остаток
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// RetryLogger вызывается перед ожиданием очередного повтора. attempt - номер повтора
+// начиная с 1, status - HTTP статус предыдущей попытки (0 при сетевой ошибке),
+// err - причина повтора, wait - задержка перед повтором
+type RetryLogger func(attempt int, status int, err error, wait time.Duration)
+
+// retryableError помечает ошибку обработки успешного ответа как повод для повтора
+type retryableError struct {
+	err error
+}
+
+// Error реализует интерфейс error
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+// retryable оборачивает ошибку, чтобы execute повторил запрос
+func retryable(err error) error {
+	return &retryableError{err: err}
+}
+
+// BackoffStrategy определяет, как растет задержка между повторами
+type BackoffStrategy int
+
+const (
+	// ExponentialBackoff удваивает задержку с каждой попыткой: base, 2*base, 4*base...
+	ExponentialBackoff BackoffStrategy = iota
+	// ConstantBackoff ждет base перед каждой попыткой
+	ConstantBackoff
+	// LinearBackoff увеличивает задержку на base с каждой попыткой: base, 2*base, 3*base...
+	LinearBackoff
+	// DecorrelatedJitter выбирает случайную задержку между base и утроенной
+	// предыдущей задержкой (алгоритм "decorrelated jitter" AWS)
+	DecorrelatedJitter
+)
+
+// defaultBackoffBase - базовая задержка повторов по умолчанию
+const defaultBackoffBase = time.Second
+
+// backoff вычисляет задержку перед повтором номер attempt (с нуля) по стратегии
+// клиента. prev - предыдущая задержка, нужна для DecorrelatedJitter. Задержка
+// ограничивается backoffMax, если он задан
+func (c *Client) backoff(attempt int, prev time.Duration) time.Duration {
+	base := c.backoffBase
+
+	var wait time.Duration
+	switch c.backoffStrategy {
+	case ConstantBackoff:
+		wait = base
+	case LinearBackoff:
+		wait = base * time.Duration(attempt+1)
+	case DecorrelatedJitter:
+		if prev < base {
+			prev = base
+		}
+		wait = base + time.Duration(rand.Int64N(int64(prev*3-base)+1))
+	default:
+		wait = time.Duration(math.Pow(2, float64(attempt)) * float64(base))
+	}
+
+	if c.backoffMax > 0 && wait > c.backoffMax {
+		wait = c.backoffMax
+	}
+	return wait
+}
+
+// Ограничения задержки из Retry-After
+const (
+	// defaultRetryAfterMax - наибольшая задержка из Retry-After, если WithBackoff
+	// не задает max
+	defaultRetryAfterMax = time.Minute
+	// retryAfterLimit - задержки больше этой считаются некорректными
+	retryAfterLimit = 24 * time.Hour
+)
+
+// retryAfter читает из ответа рекомендуемую задержку перед повтором: заголовок
+// Retry-After-Ms (миллисекунды) или Retry-After (секунды, в том числе дробные,
+// либо HTTP-дата). Второе значение равно false, если заголовков нет или они
+// некорректны, в том числе отрицательны или больше retryAfterLimit
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if value := strings.TrimSpace(resp.Header.Get("Retry-After-Ms")); value != "" {
+		if ms, err := strconv.ParseFloat(value, 64); err == nil && validRetryAfter(ms, time.Millisecond) {
+			return time.Duration(ms * float64(time.Millisecond)), true
+		}
+	}
+
+	value := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if !validRetryAfter(seconds, time.Second) {
+			return 0, false
+		}
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		wait := date.Sub(now)
+		if wait > retryAfterLimit {
+			return 0, false
+		}
+		return max(wait, 0), true
+	}
+
+	return 0, false
+}
+
+// validRetryAfter проверяет, что value единиц unit - допустимая задержка: число
+// от 0 до retryAfterLimit. Проверка в float64 исключает переполнение time.Duration
+func validRetryAfter(value float64, unit time.Duration) bool {
+	return value >= 0 && value*float64(unit) <= float64(retryAfterLimit)
+}
+
+// isEventStream сообщает, что тело ответа передается в формате Server-Sent Events
+func isEventStream(resp *http.Response) bool {
+	return strings.HasPrefix(strings.ToLower(resp.Header.Get("Content-Type")), "text/event-stream")
+}
@@ -0,0 +1,271 @@
+package llmclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_Backoff(t *testing.T) {
+	tests := []struct {
+		strategy BackoffStrategy
+		max      time.Duration
+		expected []time.Duration
+	}{
+		{ExponentialBackoff, 0, []time.Duration{100, 200, 400, 800}},
+		{ExponentialBackoff, 300, []time.Duration{100, 200, 300, 300}},
+		{ConstantBackoff, 0, []time.Duration{100, 100, 100, 100}},
+		{LinearBackoff, 0, []time.Duration{100, 200, 300, 400}},
+	}
+
+	for _, tt := range tests {
+		client := NewClient("http://localhost", "test-key", "model",
+			WithBackoffStrategy(tt.strategy),
+			WithBackoff(100*time.Millisecond, tt.max*time.Millisecond),
+		)
+
+		for attempt, expected := range tt.expected {
+			if got := client.backoff(attempt, 0); got != expected*time.Millisecond {
+				t.Errorf("strategy %d, attempt %d: expected %s, got %s", tt.strategy, attempt, expected*time.Millisecond, got)
+			}
+		}
+	}
+}
+
+func TestClient_Backoff_DecorrelatedJitter(t *testing.T) {
+	base, max := 100*time.Millisecond, time.Second
+	client := NewClient("http://localhost", "test-key", "model",
+		WithBackoffStrategy(DecorrelatedJitter),
+		WithBackoff(base, max),
+	)
+
+	var wait time.Duration
+	for attempt := 0; attempt < 50; attempt++ {
+		prev := wait
+		wait = client.backoff(attempt, wait)
+
+		upper := 3 * prev
+		if upper < 3*base {
+			upper = 3 * base
+		}
+		if upper > max {
+			upper = max
+		}
+		if wait < base || wait > upper {
+			t.Fatalf("attempt %d: wait %s outside [%s, %s]", attempt, wait, base, upper)
+		}
+	}
+}
+
+func TestClient_Backoff_Default(t *testing.T) {
+	client := NewClient("http://localhost", "test-key", "model")
+	for attempt, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if got := client.backoff(attempt, 0); got != expected {
+			t.Errorf("attempt %d: expected %s, got %s", attempt, expected, got)
+		}
+	}
+}
+
+func TestClient_WithBackoff_Retries(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	var waits []time.Duration
+	client := NewClient(server.URL, "test-key", "model",
+		WithBackoffStrategy(LinearBackoff),
+		WithBackoff(time.Millisecond, 0),
+		WithRetryLogger(func(attempt, status int, err error, wait time.Duration) {
+			waits = append(waits, wait)
+		}),
+	)
+
+	start := time.Now()
+	if _, err := client.SimpleRequest(context.Background(), "", "Hello"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected millisecond backoff, took %s", elapsed)
+	}
+	if len(waits) != 2 || waits[0] != time.Millisecond || waits[1] != 2*time.Millisecond {
+		t.Errorf("Unexpected waits: %v", waits)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected time.Duration
+		ok       bool
+	}{
+		{"integer seconds", map[string]string{"Retry-After": "3"}, 3 * time.Second, true},
+		{"fractional seconds", map[string]string{"Retry-After": "0.25"}, 250 * time.Millisecond, true},
+		{"http date", map[string]string{"Retry-After": now.Add(5 * time.Second).Format(http.TimeFormat)}, 5 * time.Second, true},
+		{"past http date", map[string]string{"Retry-After": now.Add(-time.Minute).Format(http.TimeFormat)}, 0, true},
+		{"milliseconds header", map[string]string{"Retry-After-Ms": "1500"}, 1500 * time.Millisecond, true},
+		{"milliseconds take precedence", map[string]string{"Retry-After-Ms": "20", "Retry-After": "10"}, 20 * time.Millisecond, true},
+		{"malformed milliseconds fall back", map[string]string{"Retry-After-Ms": "soon", "Retry-After": "2"}, 2 * time.Second, true},
+		{"malformed", map[string]string{"Retry-After": "soon"}, 0, false},
+		{"negative", map[string]string{"Retry-After": "-1"}, 0, false},
+		{"one day", map[string]string{"Retry-After": "86400"}, 24 * time.Hour, true},
+		{"beyond limit", map[string]string{"Retry-After": "86401"}, 0, false},
+		{"duration overflow", map[string]string{"Retry-After": "1e300"}, 0, false},
+		{"milliseconds overflow", map[string]string{"Retry-After-Ms": "1e300"}, 0, false},
+		{"NaN", map[string]string{"Retry-After": "NaN"}, 0, false},
+		{"far http date", map[string]string{"Retry-After": now.Add(48 * time.Hour).Format(http.TimeFormat)}, 0, false},
+		{"missing", nil, 0, false},
+	}
+
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		for name, value := range tt.headers {
+			resp.Header.Set(name, value)
+		}
+
+		got, ok := retryAfter(resp, now)
+		if got != tt.expected || ok != tt.ok {
+			t.Errorf("%s: expected %s, %v; got %s, %v", tt.name, tt.expected, tt.ok, got, ok)
+		}
+	}
+}
+
+func TestClient_RetryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		expected time.Duration
+	}{
+		{"server delay", "Retry-After-Ms", "5", 5 * time.Millisecond},
+		{"clamped to max backoff", "Retry-After", "120", 50 * time.Millisecond},
+		{"malformed falls back to backoff", "Retry-After", "later", 2 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				if attempts == 1 {
+					w.Header().Set(tt.header, tt.value)
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+			}))
+			defer server.Close()
+
+			var waits []time.Duration
+			client := NewClient(server.URL, "test-key", "model",
+				WithBackoff(2*time.Millisecond, 50*time.Millisecond),
+				WithRetryLogger(func(attempt, status int, err error, wait time.Duration) {
+					waits = append(waits, wait)
+				}),
+			)
+			if _, err := client.SimpleRequest(context.Background(), "", "Hello"); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(waits) != 1 || waits[0] != tt.expected {
+				t.Errorf("Expected wait %s, got %v", tt.expected, waits)
+			}
+		})
+	}
+}
+
+func TestClient_WithRetryErrorParsing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error":{"message":"upstream overloaded","type":"server_error","code":"overloaded"}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model",
+		WithMaxRetries(1), WithBackoff(time.Millisecond, time.Millisecond), WithRetryErrorParsing())
+	_, err := client.SimpleRequest(context.Background(), "sys", "user")
+	if !errors.Is(err, ErrMaxRetriesExceeded) {
+		t.Fatalf("Expected ErrMaxRetriesExceeded, got %v", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "overloaded" || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected last APIError, got %v", err)
+	}
+
+	client = NewClient(server.URL, "test-key", "model",
+		WithMaxRetries(1), WithBackoff(time.Millisecond, time.Millisecond))
+	_, err = client.SimpleRequest(context.Background(), "sys", "user")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || errors.As(err, &apiErr) {
+		t.Errorf("Expected StatusError without option, got %v", err)
+	}
+}
+
+func TestHasTransientMarker(t *testing.T) {
+	long := `{"id":"x","padding":"` + strings.Repeat("a", transientBodyPeekLimit) + `","error":{"message":"overloaded"}}`
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   bool
+	}{
+		{"error object", http.StatusOK, `{"error":{"type":"overloaded_error","message":"Overloaded"}}`, true},
+		{"null error", http.StatusOK, `{"id":"resp_1","error":null,"output":[{"content":[{"text":"the server is overloaded"}]}]}`, false},
+		{"model text", http.StatusOK, `{"choices":[{"message":{"content":"error: please try again"}}]}`, false},
+		{"truncated body", http.StatusOK, long, false},
+		{"marker outside error", http.StatusOK, `{"error":{"message":"bad request"},"note":"overloaded"}`, false},
+		{"error status", http.StatusBadRequest, `overloaded`, true},
+	}
+
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(tt.body))}
+		if got := hasTransientMarker(resp, defaultTransientMarkers); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+		if rest, _ := io.ReadAll(resp.Body); string(rest) != tt.body {
+			t.Errorf("%s: expected body to be readable in full", tt.name)
+		}
+	}
+}
+
+func TestClient_RetryAfter_DefaultCap(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "86400")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var waits []time.Duration
+	client := NewClient(server.URL, "test-key", "model",
+		WithRetryLogger(func(attempt, status int, err error, wait time.Duration) {
+			waits = append(waits, wait)
+			cancel()
+		}),
+	)
+	if _, err := client.SimpleRequest(ctx, "", "Hello"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if len(waits) != 1 || waits[0] != defaultRetryAfterMax {
+		t.Errorf("Expected wait capped at %s, got %v", defaultRetryAfterMax, waits)
+	}
+}
@@ -0,0 +1,36 @@
+package llmclient
+
+import "strings"
+
+// endpoint описывает адрес API и ключ доступа к нему
+type endpoint struct {
+	baseURL string
+	apiKey  string
+}
+
+// modelRoute направляет модели с заданным префиксом на отдельный эндпоинт
+type modelRoute struct {
+	prefix   string
+	endpoint endpoint
+}
+
+// defaultEndpoint возвращает эндпоинт, заданный в NewClient
+func (c *Client) defaultEndpoint() endpoint {
+	return endpoint{baseURL: c.baseURL, apiKey: c.apiKey}
+}
+
+// resolveEndpoint выбирает эндпоинт для модели по самому длинному совпавшему
+// префиксу из WithModelPrefix. Без совпадений используется эндпоинт по умолчанию
+func (c *Client) resolveEndpoint(model string) endpoint {
+	result := c.defaultEndpoint()
+	matched := -1
+
+	for _, route := range c.modelRoutes {
+		if strings.HasPrefix(model, route.prefix) && len(route.prefix) > matched {
+			result = route.endpoint
+			matched = len(route.prefix)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,133 @@
+package llmclient
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// fairScheduler ограничивает общую частоту запросов (token bucket) и раздает
+// освободившиеся токены ожидающим запросам по очереди моделей (round-robin),
+// чтобы поток запросов одной модели не вытеснял редкие запросы другой
+type fairScheduler struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	queues map[string][]chan struct{}
+	order  []string // модели с ожидающими запросами в порядке обслуживания
+	next   int
+	timer  *time.Timer
+}
+
+// newFairScheduler создает планировщик на rate запросов в секунду с запасом burst
+func newFairScheduler(rate float64, burst int) *fairScheduler {
+	burst = max(burst, 1)
+	return &fairScheduler{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		queues: make(map[string][]chan struct{}),
+	}
+}
+
+// acquire ожидает очереди модели и свободного токена или отмены ctx
+func (s *fairScheduler) acquire(ctx context.Context, model string) error {
+	s.mu.Lock()
+	s.refill()
+	if len(s.order) == 0 && s.tokens >= 1 {
+		s.tokens--
+		s.mu.Unlock()
+		return nil
+	}
+
+	ready := make(chan struct{})
+	if len(s.queues[model]) == 0 {
+		s.order = append(s.order, model)
+	}
+	s.queues[model] = append(s.queues[model], ready)
+	s.dispatch()
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		s.remove(model, ready)
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// refill начисляет токены за время с последнего начисления
+func (s *fairScheduler) refill() {
+	now := time.Now()
+	s.tokens = min(s.burst, s.tokens+now.Sub(s.last).Seconds()*s.rate)
+	s.last = now
+}
+
+// dispatch раздает доступные токены очередям моделей по кругу и, если ожидающие
+// остались, планирует следующую раздачу. Вызывается под s.mu
+func (s *fairScheduler) dispatch() {
+	s.refill()
+	for s.tokens >= 1 && len(s.order) > 0 {
+		s.next %= len(s.order)
+		model := s.order[s.next]
+
+		queue := s.queues[model]
+		close(queue[0])
+		s.tokens--
+
+		if len(queue) == 1 {
+			delete(s.queues, model)
+			s.order = append(s.order[:s.next], s.order[s.next+1:]...)
+		} else {
+			s.queues[model] = queue[1:]
+			s.next++
+		}
+	}
+
+	if len(s.order) == 0 || s.timer != nil {
+		return
+	}
+
+	wait := time.Duration((1 - s.tokens) / s.rate * float64(time.Second))
+	s.timer = time.AfterFunc(wait, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.timer = nil
+		s.dispatch()
+	})
+}
+
+// remove убирает из очереди модели запрос, отмененный до получения токена.
+// Если токен уже выдан, он считается израсходованным
+func (s *fairScheduler) remove(model string, ready chan struct{}) {
+	queue := s.queues[model]
+	for i, waiter := range queue {
+		if waiter != ready {
+			continue
+		}
+
+		if len(queue) > 1 {
+			s.queues[model] = append(queue[:i:i], queue[i+1:]...)
+			return
+		}
+
+		delete(s.queues, model)
+		for j, name := range s.order {
+			if name == model {
+				s.order = append(s.order[:j], s.order[j+1:]...)
+				if j < s.next {
+					s.next--
+				}
+				break
+			}
+		}
+		return
+	}
+}
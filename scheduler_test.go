@@ -0,0 +1,81 @@
+package llmclient
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitQueued ожидает, пока в очереди модели окажется n запросов
+func waitQueued(t *testing.T, s *fairScheduler, model string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		queued := len(s.queues[model])
+		s.mu.Unlock()
+		if queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Expected %d queued requests for %s", n, model)
+}
+
+func TestFairScheduler_RoundRobin(t *testing.T) {
+	s := newFairScheduler(200, 1)
+	if err := s.acquire(context.Background(), "warmup"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	start := func(model string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.acquire(context.Background(), model); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+			mu.Lock()
+			order = append(order, model)
+			mu.Unlock()
+		}()
+	}
+
+	for i := 1; i <= 4; i++ {
+		start("mini")
+		waitQueued(t, s, "mini", i)
+	}
+	start("big")
+	waitQueued(t, s, "big", 1)
+	wg.Wait()
+
+	if got := strings.Join(order, ","); got != "mini,big,mini,mini,mini" {
+		t.Errorf("Expected round-robin admission, got %s", got)
+	}
+}
+
+func TestFairScheduler_Cancel(t *testing.T) {
+	s := newFairScheduler(1, 1)
+	if err := s.acquire(context.Background(), "model"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.acquire(ctx, "model"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline error, got %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queues) != 0 || len(s.order) != 0 {
+		t.Errorf("Expected cancelled request to leave the queue, got %v %v", s.queues, s.order)
+	}
+}
@@ -0,0 +1,62 @@
+package llmclient
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"sync"
+)
+
+// headerCapture хранит последнее значение заголовка ответа для WithResponseHeaderCapture
+type headerCapture struct {
+	name  string
+	apply func(value string, req *http.Request)
+
+	mu    sync.Mutex
+	value string
+	set   bool
+}
+
+// applyCapturedHeaders передает сохраненные значения заголовков ответов в запрос
+func (c *Client) applyCapturedHeaders(req *http.Request) {
+	for _, capture := range c.headerCaptures {
+		capture.mu.Lock()
+		value, ok := capture.value, capture.set
+		capture.mu.Unlock()
+
+		if ok {
+			capture.apply(value, req)
+		}
+	}
+}
+
+// captureResponseHeaders сохраняет значения отслеживаемых заголовков ответа.
+// Ответ без заголовка не сбрасывает ранее сохраненное значение
+func (c *Client) captureResponseHeaders(resp *http.Response) {
+	for _, capture := range c.headerCaptures {
+		values := resp.Header.Values(capture.name)
+		if len(values) == 0 {
+			continue
+		}
+
+		capture.mu.Lock()
+		capture.value, capture.set = values[len(values)-1], true
+		capture.mu.Unlock()
+	}
+}
+
+// configureCookieJar устанавливает хранилище cookie WithCookieJar на копию
+// HTTP клиента, не изменяя клиент, переданный через WithHttpClient
+func (c *Client) configureCookieJar() {
+	if !c.useCookieJar {
+		return
+	}
+
+	jar := c.cookieJar
+	if jar == nil {
+		jar, _ = cookiejar.New(nil)
+	}
+
+	httpClient := *c.httpClient
+	httpClient.Jar = jar
+	c.httpClient = &httpClient
+}
@@ -0,0 +1,66 @@
+package llmclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_WithCookieJar(t *testing.T) {
+	var cookies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, _ := r.Cookie("session")
+		if cookie != nil {
+			cookies = append(cookies, cookie.Value)
+		} else {
+			cookies = append(cookies, "")
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithCookieJar(nil))
+	for i := 0; i < 2; i++ {
+		if _, err := client.SimpleRequest(context.Background(), "sys", "user"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if len(cookies) != 2 || cookies[0] != "" || cookies[1] != "abc" {
+		t.Errorf("Expected session cookie on second request, got %q", cookies)
+	}
+	if http.DefaultClient.Jar != nil {
+		t.Error("Expected http.DefaultClient to be left unchanged")
+	}
+}
+
+func TestClient_WithResponseHeaderCapture(t *testing.T) {
+	var affinity []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		affinity = append(affinity, r.Header.Get("X-Affinity"))
+		if len(affinity) == 1 {
+			w.Header().Set("X-Backend", "node-7")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model",
+		WithResponseHeaderCapture("X-Backend", func(value string, req *http.Request) {
+			req.Header.Set("X-Affinity", value)
+		}),
+	)
+	for i := 0; i < 3; i++ {
+		if _, err := client.SimpleRequest(context.Background(), "sys", "user"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if len(affinity) != 3 || affinity[0] != "" || affinity[1] != "node-7" || affinity[2] != "node-7" {
+		t.Errorf("Expected captured value on later requests, got %q", affinity)
+	}
+}
@@ -0,0 +1,97 @@
+package llmclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// flightKey возвращает ключ объединения запросов: тело и дополнительные заголовки,
+// чтобы запросы с разными заголовками не получали чужой ответ
+func flightKey(ctx context.Context, body []byte) string {
+	key := string(body)
+	for _, headers := range []http.Header{headersFromContext(ctx), requestHeadersFromContext(ctx)} {
+		if len(headers) > 0 {
+			key += "\n" + fmt.Sprint(headers)
+		}
+	}
+	return key
+}
+
+// sendsZeroTemperature сообщает, отправляется ли в теле запроса нулевая temperature.
+// Без поля провайдер выбирает свою температуру (обычно около 1), и объединять
+// такие запросы нельзя: все вызовы получили бы один и тот же случайный ответ
+func sendsZeroTemperature(body []byte) bool {
+	var fields struct {
+		Temperature *float64 `json:"temperature"`
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return false
+	}
+	return fields.Temperature != nil && *fields.Temperature == 0
+}
+
+// flightGroup объединяет одновременные вызовы с одинаковым ключом в один
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// flightCall - выполняющийся вызов, результат которого ждут другие вызовы
+type flightCall struct {
+	done    chan struct{}
+	waiters int
+	resp    ChatResponse
+	err     error
+}
+
+// do выполняет fn, если вызов с ключом key еще не выполняется, иначе ждет
+// результат уже выполняющегося вызова или отмены ctx. Каждый вызывающий получает
+// копию срезов ответа, чтобы изменения у одного не влияли на других
+func (g *flightGroup) do(ctx context.Context, key string, fn func() (ChatResponse, error)) (ChatResponse, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+
+	if call, ok := g.calls[key]; ok {
+		call.waiters++
+		g.mu.Unlock()
+
+		select {
+		case <-call.done:
+			return call.result()
+		case <-ctx.Done():
+			return ChatResponse{}, ctx.Err()
+		}
+	}
+
+	call := &flightCall{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+
+	call.resp, call.err = fn()
+	return call.result()
+}
+
+// result возвращает результат вызова с собственными копиями срезов ответа
+func (c *flightCall) result() (ChatResponse, error) {
+	resp := c.resp
+	resp.Choices = append([]Choice(nil), c.resp.Choices...)
+	for i := range resp.Choices {
+		msg := &resp.Choices[i].Message
+		msg.ToolCalls = append([]ToolCall(nil), msg.ToolCalls...)
+		msg.Parts = append([]ContentPart(nil), msg.Parts...)
+	}
+	resp.PromptFilterResults = append([]PromptFilterResult(nil), c.resp.PromptFilterResults...)
+	return resp, c.err
+}
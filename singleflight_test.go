@@ -0,0 +1,145 @@
+package llmclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// waiterCount возвращает количество вызовов, ожидающих чужой результат
+func (g *flightGroup) waiterCount() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	count := 0
+	for _, call := range g.calls {
+		count += call.waiters
+	}
+	return count
+}
+
+// newBlockingServer создает сервер, который отвечает только после закрытия release
+func newBlockingServer(requests *atomic.Int32, release chan struct{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+}
+
+func TestClient_WithSingleflight(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	server := newBlockingServer(&requests, release)
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithSingleflight(), WithDefaultTemperature(0))
+	req := ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}}
+
+	const callers = 5
+	var wg sync.WaitGroup
+	results := make([]string, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := client.Chat(context.Background(), req)
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+			resp.Choices[0].Message.Content += "!"
+			results[i] = resp.Choices[0].Message.Content
+		}(i)
+	}
+
+	for client.flights.waiterCount() < callers-1 {
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+
+	if got := requests.Load(); got != 1 {
+		t.Errorf("Expected 1 HTTP request, got %d", got)
+	}
+	for i, result := range results {
+		if result != "ok!" {
+			t.Errorf("Caller %d: expected independent copy 'ok!', got %q", i, result)
+		}
+	}
+}
+
+func TestClient_WithSingleflight_NonDeterministic(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	server := newBlockingServer(&requests, release)
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithSingleflight())
+	req := ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}, Temperature: 0.7}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Chat(context.Background(), req); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}()
+	}
+
+	for requests.Load() < 2 {
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+}
+
+func TestClient_WithSingleflight_UnsetTemperature(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	server := newBlockingServer(&requests, release)
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithSingleflight())
+	req := ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Chat(context.Background(), req); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}()
+	}
+
+	for requests.Load() < 2 {
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+}
+
+func TestFlightCall_ResultCopiesSlices(t *testing.T) {
+	call := &flightCall{resp: ChatResponse{Choices: []Choice{{Message: Message{
+		ToolCalls: []ToolCall{{ID: "call_1"}},
+		Parts:     []ContentPart{{Type: "text", Text: "a"}},
+	}}}}}
+
+	first, _ := call.result()
+	first.Choices[0].Message.ToolCalls[0].ID = "changed"
+	first.Choices[0].Message.Parts[0].Text = "changed"
+
+	second, _ := call.result()
+	if second.Choices[0].Message.ToolCalls[0].ID != "call_1" || second.Choices[0].Message.Parts[0].Text != "a" {
+		t.Errorf("Expected independent copies, got %+v", second.Choices[0].Message)
+	}
+}
@@ -0,0 +1,293 @@
+package llmclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Маркеры протокола Server-Sent Events
+var (
+	sseDataPrefix = []byte("data:")
+	sseDone       = []byte("[DONE]")
+)
+
+// ChatStream выполняет потоковый запрос и вызывает fn для каждого фрагмента ответа.
+// Следующий фрагмент читается только после возврата из fn, поэтому медленный
+// обработчик естественным образом тормозит чтение потока. Ошибка из fn прерывает поток.
+//
+// С WithStreamReconnect запрос переотправляется, если соединение оборвалось
+// до получения первого фрагмента. Обрыв после начала передачи возвращается как ошибка:
+// возобновление с середины потока не поддерживается.
+//
+// С WithStreamTotalTimeout весь поток, включая переподключения, должен завершиться
+// за заданное время, иначе возвращается *StreamTimeoutError
+func (c *Client) ChatStream(ctx context.Context, req ChatRequest, fn func(ChatStreamChunk) error) (err error) {
+	if err := c.checkModel(ctx, req.Model); err != nil {
+		return err
+	}
+
+	ctx, metrics := c.startMetrics(ctx, true)
+	defer func() {
+		metrics.finish(err)
+	}()
+
+	if c.streamTotalTimeout <= 0 {
+		return c.chatStream(ctx, req, fn)
+	}
+
+	timeoutErr := &StreamTimeoutError{Timeout: c.streamTotalTimeout}
+	streamCtx, cancel := context.WithTimeoutCause(ctx, c.streamTotalTimeout, timeoutErr)
+	defer cancel()
+
+	err = c.chatStream(streamCtx, req, fn)
+	if err != nil && ctx.Err() == nil && context.Cause(streamCtx) == timeoutErr {
+		return timeoutErr
+	}
+	return err
+}
+
+// chatStream выполняет потоковый запрос с переподключениями без общего ограничения времени
+func (c *Client) chatStream(ctx context.Context, req ChatRequest, fn func(ChatStreamChunk) error) error {
+	req, err := c.filterOutbound(c.prepareRequest(ctx, req))
+	if err != nil {
+		return err
+	}
+
+	if err := validateToolMessages(req.Messages); err != nil {
+		return err
+	}
+	req.Stream = true
+
+	body, err := c.encodeBody(req)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := c.withTokenDeadline(ctx, ctx, req)
+	defer cancel()
+	ctx = withRequestHeaders(ctx, req.Headers)
+
+	for reconnects := 0; ; reconnects++ {
+		delivered := false
+		err := c.execute(ctx, req.Model, c.chatPath(req.Model), body, func(resp *http.Response) error {
+			if resp.StatusCode != http.StatusOK {
+				body, _ := io.ReadAll(resp.Body)
+				return c.errorParser(resp.StatusCode, body)
+			}
+			return readStream(resp.Body, c.streamBufferSize, func(chunk ChatStreamChunk) error {
+				delivered = true
+				return fn(chunk)
+			})
+		})
+
+		var readErr *streamReadError
+		if err == nil || delivered || reconnects >= c.streamReconnects || ctx.Err() != nil || !errors.As(err, &readErr) {
+			return err
+		}
+	}
+}
+
+// ChatStreamChan выполняет потоковый запрос и отдает фрагменты через канал.
+//
+// Канал фрагментов небуферизован: читающая горутина блокируется, пока потребитель
+// не заберет очередной фрагмент, и не читает сеть дальше. Так медленный потребитель
+// получает обратное давление вместо неограниченной буферизации или потери данных.
+// Заблокированная отправка прерывается при отмене ctx.
+//
+// После завершения потока оба канала закрываются. В канал ошибок перед закрытием
+// отправляется ошибка потока, если она была.
+func (c *Client) ChatStreamChan(ctx context.Context, req ChatRequest) (<-chan ChatStreamChunk, <-chan error) {
+	chunks := make(chan ChatStreamChunk)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(chunks)
+
+		err := c.ChatStream(ctx, req, func(chunk ChatStreamChunk) error {
+			select {
+			case chunks <- chunk:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			errs <- err
+		}
+	}()
+
+	return chunks, errs
+}
+
+// errStopIteration прерывает поток, когда потребитель ChatSeq вышел из цикла
+var errStopIteration = errors.New("iteration stopped")
+
+// ChatSeq выполняет потоковый запрос и возвращает итератор по фрагментам ответа:
+//
+//	for chunk, err := range client.ChatSeq(ctx, req) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Print(chunk.Choices[0].Delta.Content)
+//	}
+//
+// Ошибка потока передается последним элементом с пустым фрагментом.
+// Выход из цикла закрывает соединение
+func (c *Client) ChatSeq(ctx context.Context, req ChatRequest) iter.Seq2[ChatStreamChunk, error] {
+	return func(yield func(ChatStreamChunk, error) bool) {
+		err := c.ChatStream(ctx, req, func(chunk ChatStreamChunk) error {
+			if !yield(chunk, nil) {
+				return errStopIteration
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errStopIteration) {
+			yield(ChatStreamChunk{}, err)
+		}
+	}
+}
+
+// ChatStreamSchema выполняет потоковый запрос со схемой JSON и по мере поступления
+// текста вызывает fn с частично заполненным значением типа schema: незавершенный JSON
+// дополняется закрывающими кавычками и скобками, а оборванные ключи и литералы
+// отбрасываются. fn вызывается только при изменении разобранного текста, каждый раз
+// с новым значением. После завершения потока полный ответ разбирается в schema,
+// и fn вызывается последний раз с schema и done=true.
+//
+// Если req.JSONSchema и req.ResponseFormat не заданы, схема строится по schema
+// и передается способом, выбранным WithStructuredOutputMode. Учитывается только первый
+// вариант ответа
+func (c *Client) ChatStreamSchema(ctx context.Context, req ChatRequest, schema interface{}, fn func(partial interface{}, done bool) error) error {
+	target := reflect.TypeOf(schema)
+	if target == nil || target.Kind() != reflect.Ptr {
+		return fmt.Errorf("schema must be a pointer, got %T", schema)
+	}
+
+	if req.JSONSchema == nil && req.ResponseFormat == nil {
+		jsonSchema, err := GenerateSchema(schema)
+		if err != nil {
+			return err
+		}
+		if req, err = c.applyStructuredOutput(req, jsonSchema); err != nil {
+			return err
+		}
+	}
+
+	var content strings.Builder
+	var lastParsed string
+
+	err := c.ChatStream(ctx, req, func(chunk ChatStreamChunk) error {
+		for _, choice := range chunk.Choices {
+			if choice.Index == 0 {
+				content.WriteString(choice.Delta.Content)
+			}
+		}
+
+		completed, ok := completePartialJSON(content.String())
+		if !ok || completed == lastParsed {
+			return nil
+		}
+		lastParsed = completed
+
+		partial := reflect.New(target.Elem()).Interface()
+		if err := c.unmarshalContent([]byte(completed), partial); err != nil {
+			// Промежуточное значение может не совпадать с типом поля (например,
+			// оборванное число), такие состояния пропускаются
+			return nil
+		}
+		return fn(partial, false)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := c.unmarshalSchema(cleanJSONResponse(content.String()), schema); err != nil {
+		return fmt.Errorf("failed to parse streamed response: %w", err)
+	}
+
+	return fn(schema, true)
+}
+
+// defaultStreamBufferSize - размер буфера чтения потока по умолчанию
+const defaultStreamBufferSize = 64 << 10
+
+// readStream читает события SSE из r буфером чтения размера bufferSize (при
+// bufferSize <= 0 - defaultStreamBufferSize) и передает декодированные фрагменты в fn.
+// Строки, не помещающиеся в буфер, собираются по частям, поэтому длинные строки data:
+// (например, большие аргументы вызова инструмента) не обрываются. Событие завершается
+// пустой строкой, несколько строк data: одного события объединяются через перевод строки
+func readStream(r io.Reader, bufferSize int, fn func(ChatStreamChunk) error) error {
+	if bufferSize <= 0 {
+		bufferSize = defaultStreamBufferSize
+	}
+	reader := bufio.NewReaderSize(r, bufferSize)
+	var data []byte
+
+	dispatch := func() (bool, error) {
+		if len(data) == 0 {
+			return false, nil
+		}
+		defer func() { data = data[:0] }()
+
+		if bytes.Equal(bytes.TrimSpace(data), sseDone) {
+			return true, nil
+		}
+
+		var chunk ChatStreamChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return false, fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		return false, fn(chunk)
+	}
+
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		line = bytes.TrimRight(line, "\r\n")
+
+		switch {
+		case len(line) == 0 && readErr == nil:
+			if done, err := dispatch(); done || err != nil {
+				return err
+			}
+		case bytes.HasPrefix(line, sseDataPrefix):
+			value := bytes.TrimPrefix(line[len(sseDataPrefix):], []byte(" "))
+			if len(data) > 0 {
+				data = append(data, '\n')
+			}
+			data = append(data, value...)
+		}
+
+		if readErr == io.EOF {
+			_, err := dispatch()
+			return err
+		}
+		if readErr != nil {
+			return &streamReadError{err: readErr}
+		}
+	}
+}
+
+// streamReadError описывает обрыв чтения потока на уровне соединения
+type streamReadError struct {
+	err error
+}
+
+// Error реализует интерфейс error
+func (e *streamReadError) Error() string {
+	return "failed to read stream: " + e.err.Error()
+}
+
+// Unwrap возвращает исходную ошибку чтения
+func (e *streamReadError) Unwrap() error {
+	return e.err
+}
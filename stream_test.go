@@ -0,0 +1,385 @@
+package llmclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// newStreamServer создает сервер, отдающий фрагменты с указанным содержимым в формате SSE
+func newStreamServer(t *testing.T, contents ...string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		if !req.Stream {
+			t.Error("Expected stream to be true")
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, content := range contents {
+			chunk := ChatStreamChunk{
+				Choices: []StreamChoice{{Delta: Message{Content: content}}},
+			}
+			data, _ := json.Marshal(chunk)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+}
+
+func TestClient_ChatStream(t *testing.T) {
+	server := newStreamServer(t, "Hel", "lo", "!")
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model")
+
+	var content strings.Builder
+	err := client.ChatStream(context.Background(), ChatRequest{
+		Messages: []Message{{Role: "user", Content: "Hello"}},
+	}, func(chunk ChatStreamChunk) error {
+		content.WriteString(chunk.Choices[0].Delta.Content)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if content.String() != "Hello!" {
+		t.Errorf("Expected 'Hello!', got %s", content.String())
+	}
+}
+
+func TestClient_ChatStream_CallbackError(t *testing.T) {
+	server := newStreamServer(t, "a", "b", "c")
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model")
+
+	errStop := errors.New("stop")
+	calls := 0
+	err := client.ChatStream(context.Background(), ChatRequest{
+		Messages: []Message{{Role: "user", Content: "Hello"}},
+	}, func(chunk ChatStreamChunk) error {
+		calls++
+		return errStop
+	})
+
+	if !errors.Is(err, errStop) {
+		t.Errorf("Expected callback error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected stream to stop after 1 chunk, got %d", calls)
+	}
+}
+
+func TestClient_ChatStreamChan(t *testing.T) {
+	server := newStreamServer(t, "Hel", "lo")
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model")
+	chunks, errs := client.ChatStreamChan(context.Background(), ChatRequest{
+		Messages: []Message{{Role: "user", Content: "Hello"}},
+	})
+
+	var content strings.Builder
+	for chunk := range chunks {
+		content.WriteString(chunk.Choices[0].Delta.Content)
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if content.String() != "Hello" {
+		t.Errorf("Expected 'Hello', got %s", content.String())
+	}
+}
+
+func TestClient_ChatStreamChan_CancelWhileBlocked(t *testing.T) {
+	server := newStreamServer(t, "a", "b", "c", "d")
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model")
+	ctx, cancel := context.WithCancel(context.Background())
+	chunks, errs := client.ChatStreamChan(ctx, ChatRequest{
+		Messages: []Message{{Role: "user", Content: "Hello"}},
+	})
+
+	<-chunks
+	cancel()
+
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	for range chunks {
+	}
+}
+
+func TestClient_WithStreamBufferSize(t *testing.T) {
+	long := strings.Repeat("y", 1000)
+	server := newStreamServer(t, long, "!")
+	defer server.Close()
+
+	// Наибольший размер чтения из тела ответа равен размеру буфера readStream
+	var maxRead atomic.Int64
+	recordReads := WithTransportWrapper(func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			if err == nil {
+				resp.Body = &readSizeBody{ReadCloser: resp.Body, max: &maxRead}
+			}
+			return resp, err
+		})
+	})
+
+	for _, tt := range []struct {
+		options []Option
+		want    int64
+	}{
+		{[]Option{recordReads, WithStreamBufferSize(32)}, 32},
+		{[]Option{recordReads}, defaultStreamBufferSize},
+	} {
+		maxRead.Store(0)
+		client := NewClient(server.URL, "test-key", "model", tt.options...)
+
+		var content strings.Builder
+		err := client.ChatStream(context.Background(), ChatRequest{
+			Messages: []Message{{Role: "user", Content: "Hello"}},
+		}, func(chunk ChatStreamChunk) error {
+			content.WriteString(chunk.Choices[0].Delta.Content)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if content.String() != long+"!" {
+			t.Errorf("Expected events longer than the buffer to be read, got %d bytes", content.Len())
+		}
+		if got := maxRead.Load(); got != tt.want {
+			t.Errorf("Expected reads of up to %d bytes, got %d", tt.want, got)
+		}
+	}
+}
+
+// readSizeBody запоминает наибольший размер буфера, переданного в Read
+type readSizeBody struct {
+	io.ReadCloser
+	max *atomic.Int64
+}
+
+func (b *readSizeBody) Read(p []byte) (int, error) {
+	if n := int64(len(p)); n > b.max.Load() {
+		b.max.Store(n)
+	}
+	return b.ReadCloser.Read(p)
+}
+
+func TestReadStream_LongAndSplitEvents(t *testing.T) {
+	long := strings.Repeat("x", 100*1024)
+	longChunk, _ := json.Marshal(ChatStreamChunk{Choices: []StreamChoice{{Delta: Message{Content: long}}}})
+
+	stream := "data: " + string(longChunk) + "\n\n" +
+		": keep-alive comment\n\n" +
+		"data: {\"choices\":[{\"delta\":\r\n" +
+		"data: {\"content\":\"tail\"}}]}\r\n\r\n" +
+		"data: [DONE]\n\n"
+
+	var contents []string
+	err := readStream(iotest.HalfReader(strings.NewReader(stream)), 0, func(chunk ChatStreamChunk) error {
+		contents = append(contents, chunk.Choices[0].Delta.Content)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(contents) != 2 {
+		t.Fatalf("Expected 2 chunks, got %d", len(contents))
+	}
+	if contents[0] != long {
+		t.Errorf("Expected long content of %d bytes, got %d bytes", len(long), len(contents[0]))
+	}
+	if contents[1] != "tail" {
+		t.Errorf("Expected multi-line event to be reassembled, got %q", contents[1])
+	}
+}
+
+func TestClient_WithStreamReconnect(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			conn, buf, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("Failed to hijack connection: %v", err)
+				return
+			}
+			buf.WriteString("HTTP/1.1 200 OK\r\nContent-Type: text/event-stream\r\nTransfer-Encoding: chunked\r\n\r\n")
+			buf.Flush()
+			conn.Close()
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"ok\"}}]}\n\ndata: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	req := ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}}
+	collect := func(client *Client) (string, error) {
+		var content strings.Builder
+		err := client.ChatStream(context.Background(), req, func(chunk ChatStreamChunk) error {
+			content.WriteString(chunk.Choices[0].Delta.Content)
+			return nil
+		})
+		return content.String(), err
+	}
+
+	if _, err := collect(NewClient(server.URL, "test-key", "model")); err == nil {
+		t.Fatal("Expected dropped stream to fail without reconnects")
+	}
+
+	attempts = 0
+	content, err := collect(NewClient(server.URL, "test-key", "model", WithStreamReconnect(1)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+	if content != "ok" {
+		t.Errorf("Expected 'ok', got %s", content)
+	}
+}
+
+func TestClient_ChatStreamSchema(t *testing.T) {
+	type person struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags"`
+	}
+
+	server := newStreamServer(t, `{"name":"Al`, `ice","ta`, `gs":["x",`, `"y"]}`)
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model")
+
+	var partials []person
+	var result person
+	doneCalls := 0
+	err := client.ChatStreamSchema(context.Background(), ChatRequest{
+		Messages: []Message{{Role: "user", Content: "Hello"}},
+	}, &result, func(partial interface{}, done bool) error {
+		if done {
+			doneCalls++
+			return nil
+		}
+		partials = append(partials, *partial.(*person))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(partials) == 0 || partials[0].Name != "Al" {
+		t.Errorf("Expected first partial to contain 'Al', got %+v", partials)
+	}
+	if doneCalls != 1 {
+		t.Errorf("Expected exactly one final callback, got %d", doneCalls)
+	}
+	if result.Name != "Alice" || len(result.Tags) != 2 {
+		t.Errorf("Unexpected final result: %+v", result)
+	}
+}
+
+func TestClient_ChatStream_TotalTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithStreamTotalTimeout(100*time.Millisecond))
+
+	chunks := 0
+	err := client.ChatStream(context.Background(), ChatRequest{
+		Messages: []Message{{Role: "user", Content: "Hello"}},
+	}, func(chunk ChatStreamChunk) error {
+		chunks++
+		return nil
+	})
+
+	var timeoutErr *StreamTimeoutError
+	if !errors.As(err, &timeoutErr) || !errors.Is(err, ErrStreamTimeout) {
+		t.Fatalf("Expected StreamTimeoutError, got %v", err)
+	}
+	if timeoutErr.Timeout != 100*time.Millisecond {
+		t.Errorf("Expected timeout 100ms, got %s", timeoutErr.Timeout)
+	}
+	if chunks != 1 {
+		t.Errorf("Expected 1 chunk before timeout, got %d", chunks)
+	}
+}
+
+func TestClient_ChatSeq(t *testing.T) {
+	server := newStreamServer(t, "Hel", "lo", "!")
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model")
+	req := ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}}
+
+	var content strings.Builder
+	for chunk, err := range client.ChatSeq(context.Background(), req) {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		content.WriteString(chunk.Choices[0].Delta.Content)
+	}
+	if content.String() != "Hello!" {
+		t.Errorf("Expected 'Hello!', got %s", content.String())
+	}
+
+	count := 0
+	for _, err := range client.ChatSeq(context.Background(), req) {
+		if err != nil {
+			t.Fatalf("Unexpected error after break: %v", err)
+		}
+		count++
+		break
+	}
+	if count != 1 {
+		t.Errorf("Expected loop to stop after first chunk, got %d", count)
+	}
+}
+
+func TestClient_ChatSeq_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"message":"bad request"}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model")
+
+	var lastErr error
+	for _, err := range client.ChatSeq(context.Background(), ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}}) {
+		lastErr = err
+	}
+
+	var apiErr *APIError
+	if !errors.As(lastErr, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected APIError with status 400, got %v", lastErr)
+	}
+}
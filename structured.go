@@ -0,0 +1,184 @@
+package llmclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// StructuredOutputMode задает способ запроса структурированного вывода
+// в RequestWithSchema, RequestWithSchemaVote и ChatStreamSchema
+type StructuredOutputMode int
+
+const (
+	// StructuredOutputDefault передает схему в поле json_schema запроса
+	StructuredOutputDefault StructuredOutputMode = iota
+	// StructuredOutputAuto выбирает режим по таблице возможностей модели
+	StructuredOutputAuto
+	// StructuredOutputJSONSchema использует response_format типа json_schema
+	StructuredOutputJSONSchema
+	// StructuredOutputJSONObject использует response_format типа json_object
+	// и добавляет схему в системный промпт
+	StructuredOutputJSONObject
+	// StructuredOutputPromptOnly только добавляет схему в системный промпт
+	StructuredOutputPromptOnly
+)
+
+// Типы response_format
+const (
+	ResponseFormatJSONSchema = "json_schema"
+	ResponseFormatJSONObject = "json_object"
+)
+
+// ResponseFormat задает формат ответа модели (поле response_format)
+type ResponseFormat struct {
+	Type       string                `json:"type"`
+	JSONSchema *ResponseFormatSchema `json:"json_schema,omitempty"`
+}
+
+// ResponseFormatSchema описывает схему для response_format типа json_schema
+type ResponseFormatSchema struct {
+	Name   string                 `json:"name"`
+	Schema map[string]interface{} `json:"schema"`
+	Strict bool                   `json:"strict,omitempty"`
+}
+
+// modelCapability связывает префикс имени модели с поддерживаемым режимом
+type modelCapability struct {
+	prefix string
+	mode   StructuredOutputMode
+}
+
+// structuredOutputCapabilities - таблица возможностей моделей для StructuredOutputAuto.
+// Выбирается самый длинный совпавший префикс, поэтому снимки моделей без json_schema
+// перечислены перед общими префиксами своих семейств
+var structuredOutputCapabilities = []modelCapability{
+	{"gpt-5", StructuredOutputJSONSchema},
+	{"gpt-4.1", StructuredOutputJSONSchema},
+	{"gpt-4o-2024-05-13", StructuredOutputJSONObject},
+	{"gpt-4o", StructuredOutputJSONSchema},
+	{"o1-preview", StructuredOutputPromptOnly},
+	{"o1-mini", StructuredOutputPromptOnly},
+	{"o1", StructuredOutputJSONSchema},
+	{"o3", StructuredOutputJSONSchema},
+	{"o4", StructuredOutputJSONSchema},
+	{"gpt-4-turbo", StructuredOutputJSONObject},
+	{"gpt-4", StructuredOutputPromptOnly},
+	{"gpt-3.5-turbo", StructuredOutputJSONObject},
+	{"mistral", StructuredOutputJSONObject},
+	{"deepseek", StructuredOutputJSONObject},
+	{"llama", StructuredOutputPromptOnly},
+}
+
+// structuredOutputFor возвращает режим для модели. Для неизвестных моделей
+// в режиме Auto используется json_object
+func structuredOutputFor(mode StructuredOutputMode, model string) StructuredOutputMode {
+	if mode != StructuredOutputAuto {
+		return mode
+	}
+
+	model = strings.ToLower(model)
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+
+	resolved, matched := StructuredOutputJSONObject, ""
+	for _, capability := range structuredOutputCapabilities {
+		if strings.HasPrefix(model, capability.prefix) && len(capability.prefix) > len(matched) {
+			resolved, matched = capability.mode, capability.prefix
+		}
+	}
+	return resolved
+}
+
+// applyStructuredOutput добавляет схему в запрос способом, выбранным WithStructuredOutputMode.
+// С WithSchemaExampleInjection в системный промпт также добавляется пример ответа
+func (c *Client) applyStructuredOutput(req ChatRequest, schema map[string]interface{}) (ChatRequest, error) {
+	req, err := c.applyStructuredFormat(req, schema)
+	if err != nil || !c.schemaExampleInjection {
+		return req, err
+	}
+
+	example, err := json.Marshal(exampleFromSchema(schema))
+	if err != nil {
+		return req, fmt.Errorf("failed to encode schema example: %w", err)
+	}
+	return withSystemInstruction(req, "Example of the expected response format:\n"+string(example)), nil
+}
+
+// applyStructuredFormat выбирает способ передачи схемы для модели запроса
+func (c *Client) applyStructuredFormat(req ChatRequest, schema map[string]interface{}) (ChatRequest, error) {
+	model := req.Model
+	if model == "" {
+		model = c.model
+	}
+
+	switch structuredOutputFor(c.structuredOutputMode, model) {
+	case StructuredOutputJSONSchema:
+		req.ResponseFormat = &ResponseFormat{
+			Type: ResponseFormatJSONSchema,
+			JSONSchema: &ResponseFormatSchema{
+				Name:   schemaName(schema),
+				Schema: schema,
+			},
+		}
+		return req, nil
+	case StructuredOutputJSONObject:
+		req.ResponseFormat = &ResponseFormat{Type: ResponseFormatJSONObject}
+		return withSchemaPrompt(req, schema)
+	case StructuredOutputPromptOnly:
+		return withSchemaPrompt(req, schema)
+	default:
+		req.JSONSchema = schema
+		return req, nil
+	}
+}
+
+// withSchemaPrompt дописывает схему в системный промпт (см. withSystemInstruction)
+func withSchemaPrompt(req ChatRequest, schema map[string]interface{}) (ChatRequest, error) {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return req, fmt.Errorf("failed to encode schema: %w", err)
+	}
+	return withSystemInstruction(req, "Respond only with a JSON object that matches this JSON Schema:\n"+string(data)), nil
+}
+
+// withSystemInstruction дописывает instruction в первое системное сообщение или
+// добавляет новое системное сообщение в начало. Сообщения копируются
+func withSystemInstruction(req ChatRequest, instruction string) ChatRequest {
+	messages := make([]Message, 0, len(req.Messages)+1)
+	messages = append(messages, req.Messages...)
+
+	for i := range messages {
+		if messages[i].Role != "system" {
+			continue
+		}
+		if messages[i].Content != "" {
+			instruction = messages[i].Content + "\n\n" + instruction
+		}
+		messages[i].Content = instruction
+		req.Messages = messages
+		return req
+	}
+
+	req.Messages = append([]Message{{Role: "system", Content: instruction}}, messages...)
+	return req
+}
+
+// schemaName возвращает имя схемы для response_format: title, в котором
+// недопустимые символы заменены на "_", или "response"
+func schemaName(schema map[string]interface{}) string {
+	title, _ := schema["title"].(string)
+	if title == "" {
+		return "response"
+	}
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, title)
+}
@@ -0,0 +1,126 @@
+package llmclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStructuredOutputFor(t *testing.T) {
+	tests := []struct {
+		mode  StructuredOutputMode
+		model string
+		want  StructuredOutputMode
+	}{
+		{StructuredOutputAuto, "gpt-4o-mini", StructuredOutputJSONSchema},
+		{StructuredOutputAuto, "gpt-4o-2024-05-13", StructuredOutputJSONObject},
+		{StructuredOutputAuto, "gpt-4o-2024-08-06", StructuredOutputJSONSchema},
+		{StructuredOutputAuto, "o1-mini-2024-09-12", StructuredOutputPromptOnly},
+		{StructuredOutputAuto, "o1-preview", StructuredOutputPromptOnly},
+		{StructuredOutputAuto, "o1-2024-12-17", StructuredOutputJSONSchema},
+		{StructuredOutputAuto, "openai/gpt-4.1", StructuredOutputJSONSchema},
+		{StructuredOutputAuto, "gpt-4-turbo-preview", StructuredOutputJSONObject},
+		{StructuredOutputAuto, "gpt-4-0613", StructuredOutputPromptOnly},
+		{StructuredOutputAuto, "Llama-3-70b", StructuredOutputPromptOnly},
+		{StructuredOutputAuto, "unknown-model", StructuredOutputJSONObject},
+		{StructuredOutputJSONSchema, "llama-3", StructuredOutputJSONSchema},
+		{StructuredOutputDefault, "gpt-4o", StructuredOutputDefault},
+	}
+
+	for _, tt := range tests {
+		if got := structuredOutputFor(tt.mode, tt.model); got != tt.want {
+			t.Errorf("structuredOutputFor(%v, %q) = %v, want %v", tt.mode, tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestClient_WithStructuredOutputMode(t *testing.T) {
+	var got map[string]json.RawMessage
+	var messages []Message
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, messages = nil, nil
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		json.Unmarshal(got["messages"], &messages)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"{\"name\":\"Ann\"}"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	type person struct {
+		Name string `json:"name"`
+	}
+
+	t.Run("default", func(t *testing.T) {
+		client := NewClient(server.URL, "test-key", "gpt-4o")
+		var p person
+		if err := client.RequestWithSchema(context.Background(), "sys", "Hello", &p); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, ok := got["json_schema"]; !ok {
+			t.Error("Expected json_schema field")
+		}
+		if _, ok := got["response_format"]; ok {
+			t.Error("Expected no response_format field")
+		}
+	})
+
+	t.Run("auto json_schema", func(t *testing.T) {
+		client := NewClient(server.URL, "test-key", "gpt-4o", WithStructuredOutputMode(StructuredOutputAuto))
+		var p person
+		if err := client.RequestWithSchema(context.Background(), "sys", "Hello", &p); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var format ResponseFormat
+		json.Unmarshal(got["response_format"], &format)
+		if format.Type != ResponseFormatJSONSchema || format.JSONSchema == nil || format.JSONSchema.Name != "person" {
+			t.Errorf("Unexpected response_format: %s", got["response_format"])
+		}
+		if _, ok := got["json_schema"]; ok {
+			t.Error("Expected no top-level json_schema field")
+		}
+		if messages[0].Content != "sys" {
+			t.Errorf("Expected system prompt untouched, got %q", messages[0].Content)
+		}
+		if p.Name != "Ann" {
+			t.Errorf("Expected parsed name, got %q", p.Name)
+		}
+	})
+
+	t.Run("auto json_object", func(t *testing.T) {
+		client := NewClient(server.URL, "test-key", "gpt-3.5-turbo", WithStructuredOutputMode(StructuredOutputAuto))
+		var p person
+		if err := client.RequestWithSchema(context.Background(), "sys", "Hello", &p); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var format ResponseFormat
+		json.Unmarshal(got["response_format"], &format)
+		if format.Type != ResponseFormatJSONObject || format.JSONSchema != nil {
+			t.Errorf("Unexpected response_format: %s", got["response_format"])
+		}
+		if !strings.HasPrefix(messages[0].Content, "sys\n\n") || !strings.Contains(messages[0].Content, `"name"`) {
+			t.Errorf("Expected schema in system prompt, got %q", messages[0].Content)
+		}
+	})
+
+	t.Run("prompt only", func(t *testing.T) {
+		client := NewClient(server.URL, "test-key", "gpt-4o", WithStructuredOutputMode(StructuredOutputPromptOnly))
+		var p person
+		// Сервер отвечает не потоком, проверяется только отправленный запрос
+		client.ChatStreamSchema(context.Background(), ChatRequest{
+			Messages: []Message{{Role: "user", Content: "Hello"}},
+		}, &p, func(interface{}, bool) error { return nil })
+		if _, ok := got["response_format"]; ok {
+			t.Error("Expected no response_format field")
+		}
+		if len(messages) != 2 || messages[0].Role != "system" || !strings.Contains(messages[0].Content, "JSON Schema") {
+			t.Errorf("Expected prepended system message with schema, got %+v", messages)
+		}
+	})
+}
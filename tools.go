@@ -0,0 +1,175 @@
+package llmclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// defaultMaxToolRounds - число обращений к модели в ChatWithTools по умолчанию
+const defaultMaxToolRounds = 10
+
+// ToolHandler выполняет вызов инструмента с аргументами в формате JSON и возвращает
+// результат, который передается модели в сообщении с ролью tool
+type ToolHandler func(ctx context.Context, arguments json.RawMessage) (string, error)
+
+// ToolFunc связывает описание инструмента с его обработчиком
+type ToolFunc struct {
+	Tool    Tool
+	Handler ToolHandler
+}
+
+// ForcedToolChoice - значение tool_choice, требующее вызова функции с указанным именем
+type ForcedToolChoice struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name string `json:"name"`
+	} `json:"function"`
+}
+
+// ForceTool возвращает значение ChatRequest.ToolChoice, требующее вызова функции name
+func ForceTool(name string) ForcedToolChoice {
+	choice := ForcedToolChoice{Type: "function"}
+	choice.Function.Name = name
+	return choice
+}
+
+// NewTool создает инструмент-функцию, схема аргументов которой строится по params
+// (структуре или указателю на структуру, как в GenerateSchema)
+func NewTool(name, description string, params interface{}, handler ToolHandler) (ToolFunc, error) {
+	schema, err := GenerateSchema(params)
+	if err != nil {
+		return ToolFunc{}, err
+	}
+	delete(schema, "title")
+
+	return ToolFunc{
+		Tool: Tool{
+			Type: "function",
+			Function: FunctionDefinition{
+				Name:        name,
+				Description: description,
+				Parameters:  schema,
+			},
+		},
+		Handler: handler,
+	}, nil
+}
+
+// ChatWithTools выполняет запрос с инструментами tools: пока модель возвращает вызовы
+// инструментов, их аргументы проверяются по схеме инструмента, вызываются обработчики,
+// а результаты отправляются модели. Возвращает первый ответ без вызовов инструментов.
+//
+// Аргументы, не прошедшие проверку, приводят к ошибке ToolArgumentsError, а с
+// WithToolArgumentsFeedback передаются модели для исправления. Число обращений к
+// модели ограничено WithMaxToolRounds. Учитывается только первый вариант ответа.
+// ToolChoice запроса (кроме ToolChoiceNone) действует только на первое обращение
+func (c *Client) ChatWithTools(ctx context.Context, req ChatRequest, tools []ToolFunc) (ChatResponse, error) {
+	return c.runTools(ctx, req, tools, c.Chat)
+}
+
+// ChatWithToolsStream работает как ChatWithTools, но получает ответы модели потоком:
+// текст каждого хода передается в onDelta по мере поступления, а вызовы инструментов
+// выполняются после завершения хода. Возвращает ответ, собранный из последнего потока
+func (c *Client) ChatWithToolsStream(ctx context.Context, req ChatRequest, tools []ToolFunc, onDelta func(string)) (ChatResponse, error) {
+	return c.runTools(ctx, req, tools, func(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+		var acc StreamAccumulator
+		err := c.ChatStream(ctx, req, func(chunk ChatStreamChunk) error {
+			acc.Add(chunk)
+			for _, choice := range chunk.Choices {
+				if choice.Index == 0 && choice.Delta.Content != "" && onDelta != nil {
+					onDelta(choice.Delta.Content)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return acc.Response(), err
+		}
+
+		resp := acc.Response()
+		if len(resp.Choices) == 0 {
+			return resp, ErrNoChoices
+		}
+		return resp, nil
+	})
+}
+
+// runTools выполняет цикл вызова инструментов, получая ответы модели через turn
+func (c *Client) runTools(ctx context.Context, req ChatRequest, tools []ToolFunc, turn func(context.Context, ChatRequest) (ChatResponse, error)) (ChatResponse, error) {
+	handlers := make(map[string]ToolFunc, len(tools))
+	req.Tools = append([]Tool(nil), req.Tools...)
+	for _, tool := range tools {
+		handlers[tool.Tool.Function.Name] = tool
+		req.Tools = append(req.Tools, tool.Tool)
+	}
+	req.Messages = append([]Message(nil), req.Messages...)
+
+	rounds := c.maxToolRounds
+	if rounds <= 0 {
+		rounds = defaultMaxToolRounds
+	}
+
+	for round := 0; round < rounds; round++ {
+		resp, err := turn(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+
+		msg := resp.Choices[0].Message
+		if len(msg.ToolCalls) == 0 {
+			return resp, nil
+		}
+
+		// Принудительный вызов инструмента действует только на первый ход,
+		// иначе модель не сможет дать окончательный ответ
+		if req.ToolChoice != nil && req.ToolChoice != ToolChoiceNone {
+			req.ToolChoice = nil
+		}
+
+		req.Messages = append(req.Messages, msg)
+		for _, call := range msg.ToolCalls {
+			result, err := c.callTool(ctx, handlers, call)
+			if err != nil {
+				return resp, err
+			}
+			req.Messages = append(req.Messages, Message{Role: "tool", Content: result, ToolCallID: call.ID})
+		}
+	}
+
+	return ChatResponse{}, fmt.Errorf("%w: no final answer after %d rounds", ErrToolRoundsExceeded, rounds)
+}
+
+// callTool проверяет аргументы вызова и выполняет обработчик инструмента. Ошибки
+// аргументов возвращаются как результат для модели, если включен WithToolArgumentsFeedback
+func (c *Client) callTool(ctx context.Context, handlers map[string]ToolFunc, call ToolCall) (string, error) {
+	tool, ok := handlers[call.Function.Name]
+	if !ok {
+		err := &ToolArgumentsError{Tool: call.Function.Name, CallID: call.ID, Err: fmt.Errorf("unknown tool")}
+		if c.toolArgumentsFeedback {
+			return err.Error(), nil
+		}
+		return "", err
+	}
+
+	arguments := call.Function.Arguments
+	if arguments == "" {
+		arguments = "{}"
+	}
+
+	if tool.Tool.Function.Parameters != nil {
+		if err := ValidateAgainstSchema(tool.Tool.Function.Parameters, []byte(arguments)); err != nil {
+			argErr := &ToolArgumentsError{Tool: call.Function.Name, CallID: call.ID, Err: err}
+			if c.toolArgumentsFeedback {
+				return argErr.Error() + ". Call the tool again with corrected arguments.", nil
+			}
+			return "", argErr
+		}
+	}
+
+	result, err := tool.Handler(ctx, json.RawMessage(arguments))
+	if err != nil {
+		return "", fmt.Errorf("tool %q: %w", call.Function.Name, err)
+	}
+	return result, nil
+}
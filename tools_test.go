@@ -0,0 +1,266 @@
+package llmclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type weatherArgs struct {
+	City string `json:"city" schema:"description=Город"`
+	Days int    `json:"days"`
+}
+
+func TestNewTool(t *testing.T) {
+	tool, err := NewTool("weather", "Прогноз погоды", weatherArgs{}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tool.Tool.Type != "function" || tool.Tool.Function.Name != "weather" {
+		t.Errorf("Unexpected tool: %+v", tool.Tool)
+	}
+	if _, ok := tool.Tool.Function.Parameters["title"]; ok {
+		t.Error("Expected no title in parameters")
+	}
+	if _, ok := tool.Tool.Function.Parameters["properties"].(map[string]interface{})["city"]; !ok {
+		t.Errorf("Expected city property, got %v", tool.Tool.Function.Parameters)
+	}
+}
+
+// toolServer отвечает по очереди ответами replies и сохраняет полученные запросы
+func toolServer(t *testing.T, replies ...string) (*httptest.Server, *[]ChatRequest) {
+	var mu sync.Mutex
+	var requests []ChatRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+
+		mu.Lock()
+		requests = append(requests, req)
+		reply := replies[min(len(requests), len(replies))-1]
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":` + reply + `,"finish_reason":"stop"}]}`))
+	}))
+	return server, &requests
+}
+
+func TestClient_ChatWithTools(t *testing.T) {
+	server, requests := toolServer(t,
+		`{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Paris\",\"days\":2}"}}]}`,
+		`{"role":"assistant","content":"Sunny"}`,
+	)
+	defer server.Close()
+
+	var got weatherArgs
+	tool, _ := NewTool("weather", "", weatherArgs{}, func(ctx context.Context, args json.RawMessage) (string, error) {
+		return "sunny", json.Unmarshal(args, &got)
+	})
+
+	client := NewClient(server.URL, "test-key", "model")
+	resp, err := client.ChatWithTools(context.Background(), ChatRequest{
+		Messages: []Message{{Role: "user", Content: "Weather?"}},
+	}, []ToolFunc{tool})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.Choices[0].Message.Content != "Sunny" {
+		t.Errorf("Expected final answer, got %q", resp.Choices[0].Message.Content)
+	}
+	if got.City != "Paris" || got.Days != 2 {
+		t.Errorf("Unexpected handler arguments: %+v", got)
+	}
+
+	second := (*requests)[1]
+	if len(second.Tools) != 1 || len(second.Messages) != 3 {
+		t.Fatalf("Unexpected second request: %+v", second)
+	}
+	if result := second.Messages[2]; result.Role != "tool" || result.ToolCallID != "call_1" || result.Content != "sunny" {
+		t.Errorf("Unexpected tool result message: %+v", result)
+	}
+}
+
+func TestClient_ChatWithToolsInvalidArguments(t *testing.T) {
+	invalid := `{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"city\":1,\"days\":1}"}}]}`
+
+	called := false
+	tool, _ := NewTool("weather", "", weatherArgs{}, func(context.Context, json.RawMessage) (string, error) {
+		called = true
+		return "", nil
+	})
+
+	server, _ := toolServer(t, invalid)
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model")
+	_, err := client.ChatWithTools(context.Background(), ChatRequest{
+		Messages: []Message{{Role: "user", Content: "Weather?"}},
+	}, []ToolFunc{tool})
+
+	var argErr *ToolArgumentsError
+	if !errors.Is(err, ErrInvalidToolArguments) || !errors.As(err, &argErr) {
+		t.Fatalf("Expected ErrInvalidToolArguments, got %v", err)
+	}
+	if argErr.Tool != "weather" || argErr.CallID != "call_1" || !strings.Contains(err.Error(), "$.city") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if called {
+		t.Error("Expected handler not to be called")
+	}
+}
+
+func TestClient_ChatWithToolsArgumentsFeedback(t *testing.T) {
+	server, requests := toolServer(t,
+		`{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"city\":1,\"days\":1}"}}]}`,
+		`{"role":"assistant","tool_calls":[{"id":"call_2","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Rome\",\"days\":1}"}}]}`,
+		`{"role":"assistant","content":"Rainy"}`,
+	)
+	defer server.Close()
+
+	tool, _ := NewTool("weather", "", weatherArgs{}, func(context.Context, json.RawMessage) (string, error) {
+		return "rainy", nil
+	})
+
+	client := NewClient(server.URL, "test-key", "model", WithToolArgumentsFeedback())
+	resp, err := client.ChatWithTools(context.Background(), ChatRequest{
+		Messages: []Message{{Role: "user", Content: "Weather?"}},
+	}, []ToolFunc{tool})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.Choices[0].Message.Content != "Rainy" {
+		t.Errorf("Expected final answer, got %q", resp.Choices[0].Message.Content)
+	}
+
+	feedback := (*requests)[1].Messages[2]
+	if feedback.ToolCallID != "call_1" || !strings.Contains(feedback.Content, "$.city") {
+		t.Errorf("Expected validation feedback for call_1, got %+v", feedback)
+	}
+}
+
+func TestClient_ChatWithToolsMaxRounds(t *testing.T) {
+	server, requests := toolServer(t,
+		`{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Oslo\",\"days\":1}"}}]}`,
+	)
+	defer server.Close()
+
+	tool, _ := NewTool("weather", "", weatherArgs{}, func(context.Context, json.RawMessage) (string, error) {
+		return "cold", nil
+	})
+
+	client := NewClient(server.URL, "test-key", "model", WithMaxToolRounds(2))
+	_, err := client.ChatWithTools(context.Background(), ChatRequest{
+		Messages: []Message{{Role: "user", Content: "Weather?"}},
+	}, []ToolFunc{tool})
+	if !errors.Is(err, ErrToolRoundsExceeded) {
+		t.Fatalf("Expected ErrToolRoundsExceeded, got %v", err)
+	}
+	if len(*requests) != 2 {
+		t.Errorf("Expected 2 requests, got %d", len(*requests))
+	}
+}
+
+func TestClient_ChatWithToolsStream(t *testing.T) {
+	streams := []string{
+		`data: {"choices":[{"index":0,"delta":{"role":"assistant","content":"Checking"}}]}` + "\n\n" +
+			`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"city\":"}}]}}]}` + "\n\n" +
+			`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\",\"days\":1}"}}]},"finish_reason":"tool_calls"}]}` + "\n\n" +
+			"data: [DONE]\n\n",
+		`data: {"choices":[{"index":0,"delta":{"role":"assistant","content":"Sun"}}]}` + "\n\n" +
+			`data: {"choices":[{"index":0,"delta":{"content":"ny"},"finish_reason":"stop"}]}` + "\n\n" +
+			"data: [DONE]\n\n",
+	}
+
+	var requests []ChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		requests = append(requests, req)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(streams[min(len(requests), len(streams))-1]))
+	}))
+	defer server.Close()
+
+	var got weatherArgs
+	tool, _ := NewTool("weather", "", weatherArgs{}, func(ctx context.Context, args json.RawMessage) (string, error) {
+		return "sunny", json.Unmarshal(args, &got)
+	})
+
+	var deltas []string
+	client := NewClient(server.URL, "test-key", "model")
+	resp, err := client.ChatWithToolsStream(context.Background(), ChatRequest{
+		Messages: []Message{{Role: "user", Content: "Weather in Paris?"}},
+	}, []ToolFunc{tool}, func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if resp.Choices[0].Message.Content != "Sunny" {
+		t.Errorf("Expected final answer, got %q", resp.Choices[0].Message.Content)
+	}
+	if strings.Join(deltas, "|") != "Checking|Sun|ny" {
+		t.Errorf("Unexpected deltas: %v", deltas)
+	}
+	if got.City != "Paris" || got.Days != 1 {
+		t.Errorf("Unexpected tool arguments: %+v", got)
+	}
+	if len(requests) != 2 || !requests[1].Stream {
+		t.Fatalf("Expected 2 streaming requests, got %+v", requests)
+	}
+	history := requests[1].Messages
+	if len(history) != 3 || history[1].ToolCalls[0].ID != "call_1" || history[2].ToolCallID != "call_1" || history[2].Content != "sunny" {
+		t.Errorf("Unexpected history: %+v", history)
+	}
+}
+
+func TestForceTool(t *testing.T) {
+	data, err := json.Marshal(ChatRequest{Model: "m", ToolChoice: ForceTool("weather")})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(string(data), `"tool_choice":{"type":"function","function":{"name":"weather"}}`) {
+		t.Errorf("Unexpected tool_choice: %s", data)
+	}
+
+	data, _ = json.Marshal(ChatRequest{Model: "m", ToolChoice: ToolChoiceRequired})
+	if !strings.Contains(string(data), `"tool_choice":"required"`) {
+		t.Errorf("Unexpected tool_choice: %s", data)
+	}
+}
+
+func TestClient_ChatWithTools_ForcedChoiceFirstRoundOnly(t *testing.T) {
+	server, requests := toolServer(t,
+		`{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Paris\",\"days\":1}"}}]}`,
+		`{"role":"assistant","content":"Sunny"}`,
+	)
+	defer server.Close()
+
+	tool, _ := NewTool("weather", "", weatherArgs{}, func(ctx context.Context, args json.RawMessage) (string, error) {
+		return "sunny", nil
+	})
+
+	client := NewClient(server.URL, "test-key", "model")
+	_, err := client.ChatWithTools(context.Background(), ChatRequest{
+		Messages:   []Message{{Role: "user", Content: "Weather?"}},
+		ToolChoice: ForceTool("weather"),
+	}, []ToolFunc{tool})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(*requests) != 2 || (*requests)[0].ToolChoice == nil || (*requests)[1].ToolChoice != nil {
+		t.Errorf("Expected tool_choice only in the first request, got %+v", *requests)
+	}
+}
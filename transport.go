@@ -0,0 +1,85 @@
+package llmclient
+
+import (
+	"crypto/tls"
+	"net/http"
+	"strings"
+)
+
+// configureTransport применяет накопленные опции транспорта к копии текущего
+// *http.Transport, не изменяя http.DefaultTransport и транспорт, переданный
+// через WithHttpClient. Кастомный RoundTripper другого типа остается без изменений.
+// Транспорты WithHostTransport используются для своих хостов как есть, остальные
+// хосты обслуживает настроенный транспорт. Затем транспорт оборачивается обертками
+// WithTransportWrapper в порядке их указания: первая обертка оказывается ближе всего к сети
+func (c *Client) configureTransport() {
+	if len(c.transportMods) == 0 && len(c.transportWrappers) == 0 && len(c.hostTransports) == 0 {
+		return
+	}
+
+	var transport http.RoundTripper = c.httpClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	if base, ok := transport.(*http.Transport); ok && len(c.transportMods) > 0 {
+		base = base.Clone()
+		for _, mod := range c.transportMods {
+			mod(base)
+		}
+		transport = base
+	}
+
+	if len(c.hostTransports) > 0 {
+		transport = &hostTransport{hosts: c.hostTransports, fallback: transport}
+	}
+
+	for _, wrap := range c.transportWrappers {
+		transport = wrap(transport)
+	}
+
+	httpClient := *c.httpClient
+	httpClient.Transport = transport
+	c.httpClient = &httpClient
+}
+
+// hostTransport выбирает транспорт по хосту запроса
+type hostTransport struct {
+	hosts    map[string]http.RoundTripper
+	fallback http.RoundTripper
+}
+
+// RoundTrip реализует http.RoundTripper. Хост сравнивается без учета регистра
+// сначала вместе с портом, затем без него
+func (t *hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt, ok := t.hosts[strings.ToLower(req.URL.Host)]; ok {
+		return rt.RoundTrip(req)
+	}
+	if rt, ok := t.hosts[strings.ToLower(req.URL.Hostname())]; ok {
+		return rt.RoundTrip(req)
+	}
+	return t.fallback.RoundTrip(req)
+}
+
+// forceHTTP1 отключает согласование HTTP/2, в том числе через ALPN
+func forceHTTP1(t *http.Transport) {
+	t.ForceAttemptHTTP2 = false
+	t.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	t.Protocols = nil
+
+	if t.TLSClientConfig != nil {
+		protos := make([]string, 0, len(t.TLSClientConfig.NextProtos))
+		for _, proto := range t.TLSClientConfig.NextProtos {
+			if proto != "h2" {
+				protos = append(protos, proto)
+			}
+		}
+		t.TLSClientConfig.NextProtos = protos
+	}
+}
+
+// forceHTTP2 включает попытку HTTP/2 даже при кастомных настройках TLS и dialer
+func forceHTTP2(t *http.Transport) {
+	t.ForceAttemptHTTP2 = true
+	t.TLSNextProto = nil
+}
@@ -1,40 +1,300 @@
 package llmclient
 
-// Message представляет сообщение в чате
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Message представляет сообщение в чате. Если заданы Parts, они отправляются
+// в поле content вместо Content (см. MarshalJSON)
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string        `json:"role"`
+	Content    string        `json:"content"`
+	Parts      []ContentPart `json:"-"`
+	ToolCalls  []ToolCall    `json:"tool_calls,omitempty"`
+	ToolCallID string        `json:"tool_call_id,omitempty"`
+	Audio      *AudioOutput  `json:"audio,omitempty"`
+
+	// ReasoningContent - рассуждения reasoning-модели (поле reasoning_content или
+	// reasoning ответа). В запрос не отправляется: провайдеры отклоняют его в истории
+	ReasoningContent string `json:"-"`
+}
+
+// ToolCall представляет вызов инструмента, запрошенный моделью
+type ToolCall struct {
+	// Index - позиция вызова во фрагментах потока, по которой склеиваются его части
+	Index    *int         `json:"index,omitempty"`
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Function FunctionCall `json:"function"`
+}
+
+// Tool описывает инструмент, который модель может вызвать
+type Tool struct {
+	Type     string             `json:"type"`
+	Function FunctionDefinition `json:"function"`
+}
+
+// FunctionDefinition описывает функцию инструмента и JSON Schema ее аргументов
+type FunctionDefinition struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// FunctionCall представляет имя функции и ее аргументы в формате JSON
+type FunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
 }
 
 // ChatRequest представляет запрос к API чат-комплишенов
 type ChatRequest struct {
-	Model            string                 `json:"model"`
-	Messages         []Message              `json:"messages"`
-	Temperature      float32                `json:"temperature,omitempty"`
-	TopP             float32                `json:"top_p,omitempty"`
-	MaxTokens        int                    `json:"max_tokens,omitempty"`
-	Stop             []string               `json:"stop,omitempty"`
-	N                int                    `json:"n,omitempty"`
-	PresencePenalty  float32                `json:"presence_penalty,omitempty"`
-	FrequencyPenalty float32                `json:"frequency_penalty,omitempty"`
-	JSONSchema       map[string]interface{} `json:"json_schema,omitempty"`
+	Model               string                 `json:"model"`
+	Messages            []Message              `json:"messages"`
+	Temperature         float32                `json:"temperature,omitempty"`
+	TopP                float32                `json:"top_p,omitempty"`
+	TopK                *int                   `json:"top_k,omitempty"`
+	MinP                *float32               `json:"min_p,omitempty"`
+	RepetitionPenalty   *float32               `json:"repetition_penalty,omitempty"`
+	MaxTokens           int                    `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int                    `json:"max_completion_tokens,omitempty"`
+	Stop                []string               `json:"stop,omitempty"`
+	N                   int                    `json:"n,omitempty"`
+	PresencePenalty     float32                `json:"presence_penalty,omitempty"`
+	FrequencyPenalty    float32                `json:"frequency_penalty,omitempty"`
+	JSONSchema          map[string]interface{} `json:"json_schema,omitempty"`
+	ResponseFormat      *ResponseFormat        `json:"response_format,omitempty"`
+	Logprobs            bool                   `json:"logprobs,omitempty"`
+	TopLogprobs         int                    `json:"top_logprobs,omitempty"`
+	ReasoningEffort     string                 `json:"reasoning_effort,omitempty"`
+	Seed                *int                   `json:"seed,omitempty"`
+	PromptCacheKey      string                 `json:"prompt_cache_key,omitempty"`
+	ServiceTier         string                 `json:"service_tier,omitempty"`
+	Tools               []Tool                 `json:"tools,omitempty"`
+	ToolChoice          interface{}            `json:"tool_choice,omitempty"`
+	Modalities          []string               `json:"modalities,omitempty"`
+	Audio               *AudioConfig           `json:"audio,omitempty"`
+	Stream              bool                   `json:"stream,omitempty"`
+	StreamOptions       *StreamOptions         `json:"stream_options,omitempty"`
+
+	// Headers добавляются к HTTP запросу и заменяют одноименные заголовки
+	// из контекста (ContextWithHeaders) и клиента (WithGlobalHeaders)
+	Headers http.Header `json:"-"`
+
+	// explicitTemperature - отправить temperature, даже если она равна 0
+	// (нулевая температура по умолчанию клиента, см. WithDefaultTemperature)
+	explicitTemperature bool
+
+	// extras - дополнительные поля тела запроса из WithProviderExtras
+	extras map[string]interface{}
+}
+
+// MarshalJSON отправляет нулевую temperature, если она задана настройками клиента,
+// и добавляет поля WithProviderExtras, которых нет среди полей запроса
+func (r ChatRequest) MarshalJSON() ([]byte, error) {
+	type request ChatRequest
+
+	var data []byte
+	var err error
+	if r.explicitTemperature {
+		data, err = json.Marshal(struct {
+			request
+			Temperature float32 `json:"temperature"`
+		}{request: request(r), Temperature: r.Temperature})
+	} else {
+		data, err = json.Marshal(request(r))
+	}
+	if err != nil || len(r.extras) == 0 {
+		return data, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for key, value := range r.extras {
+		if _, ok := fields[key]; ok {
+			continue
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("provider extra %q: %w", key, err)
+		}
+		fields[key] = raw
+	}
+	return json.Marshal(fields)
 }
 
+// StreamOptions представляет настройки потоковой передачи
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage,omitempty"`
+}
+
+// Значения service_tier
+const (
+	ServiceTierAuto    = "auto"
+	ServiceTierDefault = "default"
+	ServiceTierFlex    = "flex"
+)
+
+// Уровни reasoning_effort для reasoning-моделей
+const (
+	ReasoningEffortLow    = "low"
+	ReasoningEffortMedium = "medium"
+	ReasoningEffortHigh   = "high"
+)
+
+// Значения tool_choice. Для вызова конкретного инструмента используйте ForceTool
+const (
+	ToolChoiceAuto     = "auto"
+	ToolChoiceNone     = "none"
+	ToolChoiceRequired = "required"
+)
+
 // Choice представляет один вариант ответа
 type Choice struct {
-	Message      Message `json:"message"`
-	FinishReason string  `json:"finish_reason"`
+	Message      Message         `json:"message"`
+	FinishReason string          `json:"finish_reason"`
+	Logprobs     *ChoiceLogprobs `json:"logprobs,omitempty"`
+
+	// ContentFilterResults - результаты фильтрации ответа (Azure OpenAI)
+	ContentFilterResults *ContentFilterResults `json:"content_filter_results,omitempty"`
+}
+
+// ContentFilterResults описывает результаты фильтрации содержимого Azure OpenAI
+// по категориям. Категории, которые провайдер не проверял, равны nil
+type ContentFilterResults struct {
+	Hate                  *ContentFilterResult `json:"hate,omitempty"`
+	SelfHarm              *ContentFilterResult `json:"self_harm,omitempty"`
+	Sexual                *ContentFilterResult `json:"sexual,omitempty"`
+	Violence              *ContentFilterResult `json:"violence,omitempty"`
+	Profanity             *ContentFilterResult `json:"profanity,omitempty"`
+	Jailbreak             *ContentFilterResult `json:"jailbreak,omitempty"`
+	ProtectedMaterialText *ContentFilterResult `json:"protected_material_text,omitempty"`
+	ProtectedMaterialCode *ContentFilterResult `json:"protected_material_code,omitempty"`
+}
+
+// ContentFilterResult - результат фильтрации по одной категории: Severity задается
+// для категорий с уровнем (safe, low, medium, high), Detected - для детекторов
+type ContentFilterResult struct {
+	Filtered bool   `json:"filtered"`
+	Severity string `json:"severity,omitempty"`
+	Detected *bool  `json:"detected,omitempty"`
+}
+
+// PromptFilterResult - результат фильтрации запроса (Azure OpenAI)
+type PromptFilterResult struct {
+	PromptIndex          int                  `json:"prompt_index"`
+	ContentFilterResults ContentFilterResults `json:"content_filter_results"`
+}
+
+// ChoiceLogprobs содержит логарифмы вероятностей токенов ответа
+// (возвращаются, если в запросе задан Logprobs)
+type ChoiceLogprobs struct {
+	Content []TokenLogprob `json:"content"`
+}
+
+// TokenLogprob представляет логарифм вероятности токена и наиболее
+// вероятные альтернативы (TopLogprobs в запросе)
+type TokenLogprob struct {
+	Token       string       `json:"token"`
+	Logprob     float64      `json:"logprob"`
+	Bytes       []int        `json:"bytes,omitempty"`
+	TopLogprobs []TopLogprob `json:"top_logprobs,omitempty"`
+}
+
+// TopLogprob представляет альтернативный токен и его логарифм вероятности
+type TopLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	Bytes   []int   `json:"bytes,omitempty"`
+}
+
+// AvgLogprob возвращает средний логарифм вероятности токенов ответа.
+// Второе значение равно false, если логарифмы вероятностей отсутствуют
+func (l *ChoiceLogprobs) AvgLogprob() (float64, bool) {
+	if l == nil || len(l.Content) == 0 {
+		return 0, false
+	}
+
+	var sum float64
+	for _, token := range l.Content {
+		sum += token.Logprob
+	}
+	return sum / float64(len(l.Content)), true
 }
 
 // Usage представляет информацию об использовании токенов
 type Usage struct {
-	PromptTokens     int `json:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens"`
-	TotalTokens      int `json:"total_tokens"`
+	PromptTokens            int                      `json:"prompt_tokens"`
+	CompletionTokens        int                      `json:"completion_tokens"`
+	TotalTokens             int                      `json:"total_tokens"`
+	PromptTokensDetails     *PromptTokensDetails     `json:"prompt_tokens_details,omitempty"`
+	CompletionTokensDetails *CompletionTokensDetails `json:"completion_tokens_details,omitempty"`
+}
+
+// isZero сообщает, что сервер не вернул данных об использовании токенов
+func (u Usage) isZero() bool {
+	return u.PromptTokens == 0 && u.CompletionTokens == 0 && u.TotalTokens == 0 &&
+		u.PromptTokensDetails == nil && u.CompletionTokensDetails == nil
+}
+
+// PromptTokensDetails представляет детализацию токенов запроса
+type PromptTokensDetails struct {
+	CachedTokens int `json:"cached_tokens,omitempty"`
+	AudioTokens  int `json:"audio_tokens,omitempty"`
+}
+
+// CompletionTokensDetails представляет детализацию токенов ответа
+type CompletionTokensDetails struct {
+	ReasoningTokens          int `json:"reasoning_tokens,omitempty"`
+	AudioTokens              int `json:"audio_tokens,omitempty"`
+	AcceptedPredictionTokens int `json:"accepted_prediction_tokens,omitempty"`
+	RejectedPredictionTokens int `json:"rejected_prediction_tokens,omitempty"`
 }
 
 // ChatResponse представляет ответ от API
 type ChatResponse struct {
-	Choices []Choice `json:"choices"`
-	Usage   Usage    `json:"usage"`
+	Model       string   `json:"model,omitempty"`
+	Choices     []Choice `json:"choices"`
+	Usage       Usage    `json:"usage"`
+	ServiceTier string   `json:"service_tier,omitempty"`
+
+	// PromptFilterResults - результаты фильтрации запроса (Azure OpenAI)
+	PromptFilterResults []PromptFilterResult `json:"prompt_filter_results,omitempty"`
+
+	// Error - поле error успешного ответа, которое некоторые шлюзы возвращают
+	// вместе с пригодными вариантами ответа
+	Error json.RawMessage `json:"error,omitempty"`
+
+	// SoftError - некритичная ошибка, найденная WithSoftErrorDetector.
+	// Ответ при этом возвращается как успешный
+	SoftError error `json:"-"`
+}
+
+// AssistantMessage возвращает сообщение первого варианта ответа (вместе с tool_calls)
+// для добавления в историю диалога. Второе значение равно false, если вариантов нет
+func (r ChatResponse) AssistantMessage() (Message, bool) {
+	if len(r.Choices) == 0 {
+		return Message{}, false
+	}
+	return r.Choices[0].Message, true
+}
+
+// ChatStreamChunk представляет один фрагмент потокового ответа
+type ChatStreamChunk struct {
+	ID          string         `json:"id"`
+	Model       string         `json:"model"`
+	Choices     []StreamChoice `json:"choices"`
+	Usage       *Usage         `json:"usage,omitempty"`
+	ServiceTier string         `json:"service_tier,omitempty"`
+}
+
+// StreamChoice представляет приращение одного варианта ответа
+type StreamChoice struct {
+	Index        int     `json:"index"`
+	Delta        Message `json:"delta"`
+	FinishReason string  `json:"finish_reason"`
 }
@@ -0,0 +1,64 @@
+package llmclient
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// withUsageGrace отвязывает ctx запроса от отмены вызывающим, если задан
+// WithUsageGracePeriod. Пока ответ не начал обрабатываться, отмена передается
+// сразу; после вызова started чтение тела ответа продолжается еще не дольше
+// usageGrace, чтобы не потерять usage уже оплаченного ответа. Дедлайн ctx
+// сохраняется для заголовка WithDeadlinePropagation. stop освобождает
+// ресурсы и должен быть вызван по завершении запроса
+func (c *Client) withUsageGrace(ctx context.Context) (graceCtx context.Context, started func(), stop func()) {
+	if c.usageGrace <= 0 {
+		return ctx, func() {}, func() {}
+	}
+
+	graceCtx, cancel := context.WithCancel(withCallerDeadline(context.WithoutCancel(ctx), ctx))
+	var responseStarted atomic.Bool
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+		}
+
+		if responseStarted.Load() {
+			timer := time.NewTimer(c.usageGrace)
+			defer timer.Stop()
+			select {
+			case <-done:
+			case <-timer.C:
+			}
+		}
+		cancel()
+	}()
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			close(done)
+			cancel()
+		})
+	}
+	return graceCtx, func() { responseStarted.Store(true) }, stop
+}
+
+// reportUsage передает usage ответа в WithUsageCallback
+func (c *Client) reportUsage(req ChatRequest, resp ChatResponse) {
+	if c.usageCallback == nil {
+		return
+	}
+
+	model := resp.Model
+	if model == "" {
+		model = req.Model
+	}
+	c.usageCallback(model, resp.Usage)
+}
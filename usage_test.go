@@ -0,0 +1,126 @@
+package llmclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// slowBodyServer отправляет заголовки и начало тела, затем вызывает cancel
+// и через delay отправляет остаток тела
+func slowBodyServer(cancel *context.CancelFunc, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"model":"m-2024","choices":[{"message":{"role":"assistant","content":"ok"},`))
+		w.(http.Flusher).Flush()
+
+		time.Sleep(10 * time.Millisecond)
+		(*cancel)()
+		time.Sleep(delay)
+
+		w.Write([]byte(`"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`))
+	}))
+}
+
+func TestClient_WithUsageGracePeriod(t *testing.T) {
+	var cancel context.CancelFunc
+	server := slowBodyServer(&cancel, 50*time.Millisecond)
+	defer server.Close()
+
+	for _, tt := range []struct {
+		name      string
+		grace     time.Duration
+		wantUsage bool
+	}{
+		{name: "without grace", grace: 0, wantUsage: false},
+		{name: "with grace", grace: time.Second, wantUsage: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var reported []Usage
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+			defer cancel()
+
+			client := NewClient(server.URL, "test-key", "model",
+				WithUsageGracePeriod(tt.grace),
+				WithUsageCallback(func(model string, usage Usage) {
+					if model != "m-2024" {
+						t.Errorf("Expected response model, got %q", model)
+					}
+					reported = append(reported, usage)
+				}),
+			)
+
+			resp, err := client.Chat(ctx, ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}})
+			if tt.wantUsage {
+				if err != nil {
+					t.Fatalf("Expected response within grace period, got %v", err)
+				}
+				if len(reported) != 1 || reported[0].TotalTokens != 7 || resp.Usage.TotalTokens != 7 {
+					t.Errorf("Expected usage to be reported, got %v", reported)
+				}
+				return
+			}
+
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("Expected context.Canceled, got %v", err)
+			}
+			if len(reported) != 0 {
+				t.Errorf("Expected no usage, got %v", reported)
+			}
+		})
+	}
+}
+
+func TestClient_WithUsageGracePeriodCancelBeforeResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model", WithUsageGracePeriod(time.Minute))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.Chat(ctx, ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected caller deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected cancellation before response to be immediate, took %s", elapsed)
+	}
+}
+
+func TestClient_WithUsageGracePeriodDeadlinePropagation(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(DeadlineHeaderMilliseconds)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "model",
+		WithDeadlinePropagation(DeadlineHeaderMilliseconds),
+		WithUsageGracePeriod(time.Second),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := client.Chat(ctx, ChatRequest{Messages: []Message{{Role: "user", Content: "Hello"}}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ms, err := strconv.Atoi(got)
+	if err != nil || ms <= 4000 || ms > 5000 {
+		t.Errorf("Expected remaining milliseconds close to 5000, got %q", got)
+	}
+}
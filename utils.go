@@ -1,18 +1,30 @@
 package llmclient
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 )
 
+// schemaCache хранит построенные схемы по reflect.Type
+var schemaCache sync.Map
+
 // GenerateSchema создает JSON Schema для переданного экземпляра структуры.
-func GenerateSchema(instance interface{}) (map[string]interface{}, error) {
+// Необязательный title переопределяет заголовок корневого объекта,
+// который по умолчанию равен имени структуры.
+func GenerateSchema(instance interface{}, title ...string) (map[string]interface{}, error) {
 	// Получаем информацию о типе переданного экземпляра
 	t := reflect.TypeOf(instance)
+	if t == nil {
+		return nil, fmt.Errorf("ожидалась структура, получен nil")
+	}
 
 	// Убеждаемся, что работаем с конкретным типом, а не с указателем
-	if t.Kind() == reflect.Ptr {
+	// (в том числе многоуровневым, например **T)
+	for t.Kind() == reflect.Ptr {
 		t = t.Elem()
 	}
 
@@ -21,18 +33,101 @@ func GenerateSchema(instance interface{}) (map[string]interface{}, error) {
 		return nil, fmt.Errorf("ожидалась структура, получен %s", t.Kind())
 	}
 
-	// Запускаем рекурсивную генерацию
-	return generateSchemaForType(t)
+	// Схема типа строится один раз, вызывающие получают копию кэша
+	var schema map[string]interface{}
+	if cached, ok := schemaCache.Load(t); ok {
+		schema = copySchema(cached.(map[string]interface{}))
+	} else {
+		generated, err := generateSchemaForType(t)
+		if err != nil {
+			return nil, err
+		}
+		schemaCache.Store(t, generated)
+		schema = copySchema(generated)
+	}
+
+	if len(title) > 0 && title[0] != "" {
+		schema["title"] = title[0]
+	}
+
+	return schema, nil
+}
+
+// copySchema возвращает глубокую копию схемы, чтобы изменения у вызывающего
+// не затрагивали кэш
+func copySchema(schema map[string]interface{}) map[string]interface{} {
+	return copySchemaValue(schema).(map[string]interface{})
 }
 
-// generateSchemaForType - рекурсивная функция для построения схемы на основе reflect.Type.
+// copySchemaValue рекурсивно копирует значения схемы
+func copySchemaValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			copied[key] = copySchemaValue(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = copySchemaValue(item)
+		}
+		return copied
+	case []string:
+		return append([]string(nil), v...)
+	default:
+		return v
+	}
+}
+
+// schemaGenerator строит схему корневого типа root. Рекурсивные структуры
+// (например, узел списка с полем Next *Node) заменяются ссылками $ref: на корень -
+// "#", на остальные типы - "#/$defs/<имя типа>". Одноименные типы из разных
+// пакетов получают в $defs имена с числовым суффиксом
+type schemaGenerator struct {
+	root      reflect.Type
+	visiting  map[reflect.Type]bool
+	embedding map[reflect.Type]bool
+	recursive map[reflect.Type]bool
+	defs      map[string]interface{}
+	defNames  map[reflect.Type]string
+}
+
+// generateSchemaForType строит схему для reflect.Type, добавляя определения
+// рекурсивных типов в $defs корневой схемы
 func generateSchemaForType(t reflect.Type) (map[string]interface{}, error) {
+	g := &schemaGenerator{
+		root:      t,
+		visiting:  make(map[reflect.Type]bool),
+		embedding: make(map[reflect.Type]bool),
+		recursive: make(map[reflect.Type]bool),
+		defs:      make(map[string]interface{}),
+		defNames:  make(map[reflect.Type]string),
+	}
+
+	schema, err := g.generate(t)
+	if err != nil {
+		return nil, err
+	}
+	if len(g.defs) > 0 {
+		schema["$defs"] = g.defs
+	}
+	return schema, nil
+}
+
+// generate - рекурсивная функция для построения схемы на основе reflect.Type.
+func (g *schemaGenerator) generate(t reflect.Type) (map[string]interface{}, error) {
 	// Используем Kind для определения основного типа данных
 	switch t.Kind() {
 	case reflect.Struct:
-		return generateObjectSchema(t)
+		return g.generateStruct(t)
 	case reflect.Slice, reflect.Array:
-		return generateArraySchema(t)
+		// encoding/json кодирует []byte строкой base64
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}, nil
+		}
+		return g.generateArraySchema(t)
 	case reflect.String:
 		return map[string]interface{}{"type": "string"}, nil
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
@@ -43,22 +138,93 @@ func generateSchemaForType(t reflect.Type) (map[string]interface{}, error) {
 	case reflect.Bool:
 		return map[string]interface{}{"type": "boolean"}, nil
 	case reflect.Ptr:
-		// "Разыменовываем" указатель и рекурсивно вызываем для базового типа
-		return generateSchemaForType(t.Elem())
+		// "Разыменовываем" указатели любой глубины (**T) до базового типа
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		return g.generate(t)
+	case reflect.Interface:
+		// interface{} и any допускают любое JSON значение
+		return map[string]interface{}{}, nil
+	case reflect.Map:
+		return g.generateMapSchema(t)
 	default:
-		// Для других типов, таких как map, func и т.д., можно добавить свою логику
+		// Для других типов, таких как func, chan и т.д., можно добавить свою логику
 		return nil, fmt.Errorf("неподдерживаемый тип: %s", t.Kind())
 	}
 }
 
+// generateStruct создает схему структуры или ссылку на нее, если структура
+// встречается внутри самой себя
+func (g *schemaGenerator) generateStruct(t reflect.Type) (map[string]interface{}, error) {
+	if g.visiting[t] {
+		if t == g.root {
+			return map[string]interface{}{"$ref": "#"}, nil
+		}
+		g.recursive[t] = true
+		return map[string]interface{}{"$ref": "#/$defs/" + g.defName(t)}, nil
+	}
+
+	g.visiting[t] = true
+	schema, err := g.generateObjectSchema(t)
+	delete(g.visiting, t)
+	if err != nil || !g.recursive[t] {
+		return schema, err
+	}
+
+	g.defs[g.defName(t)] = schema
+	return map[string]interface{}{"$ref": "#/$defs/" + g.defName(t)}, nil
+}
+
+// defName возвращает имя типа в $defs, уникальное в пределах схемы
+func (g *schemaGenerator) defName(t reflect.Type) string {
+	if name, ok := g.defNames[t]; ok {
+		return name
+	}
+
+	taken := make(map[string]bool, len(g.defNames))
+	for _, name := range g.defNames {
+		taken[name] = true
+	}
+
+	name := t.Name()
+	for i := 2; taken[name]; i++ {
+		name = t.Name() + strconv.Itoa(i)
+	}
+	g.defNames[t] = name
+	return name
+}
+
+// generateEmbedded создает схему встроенной структуры, свойства которой поднимаются
+// в родительский объект. Встроенная структура разворачивается, даже если она
+// рекурсивна (например, Base с полем Children []*Base): ссылки получают только
+// ее поля. Ошибкой считается лишь встраивание структуры в саму себя
+func (g *schemaGenerator) generateEmbedded(t reflect.Type) (map[string]interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if g.embedding[t] {
+		return nil, fmt.Errorf("рекурсивное встраивание структуры %s", t)
+	}
+
+	g.embedding[t] = true
+	defer delete(g.embedding, t)
+	return g.generateObjectSchema(t)
+}
+
 // generateObjectSchema создает схему для объекта (структуры)
-func generateObjectSchema(t reflect.Type) (map[string]interface{}, error) {
+func (g *schemaGenerator) generateObjectSchema(t reflect.Type) (map[string]interface{}, error) {
 	schema := map[string]interface{}{
 		"type":       "object",
 		"properties": make(map[string]interface{}),
 	}
 	requiredFields := []string{}
 
+	// Для именованных структур добавляем заголовок, анонимные остаются без него
+	if t.Name() != "" {
+		schema["title"] = t.Name()
+	}
+
 	// Итерируемся по всем полям структуры
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
@@ -68,19 +234,22 @@ func generateObjectSchema(t reflect.Type) (map[string]interface{}, error) {
 			continue
 		}
 
-		// Обработка встроенных (анонимных) структур
-		if field.Anonymous {
+		// Обработка встроенных (анонимных) структур. Как и в encoding/json, поля
+		// встраиваются, только если в json теге не задано имя
+		if field.Anonymous && isEmbeddedObject(field) {
 			// Рекурсивно получаем схему для встроенной структуры
-			embeddedSchema, err := generateSchemaForType(field.Type)
+			embeddedSchema, err := g.generateEmbedded(field.Type)
 			if err != nil {
 				return nil, err
 			}
+			embeddedProperties := embeddedSchema["properties"].(map[string]interface{})
 			// Копируем свойства из встроенной схемы в текущую
-			for key, value := range embeddedSchema["properties"].(map[string]interface{}) {
+			for key, value := range embeddedProperties {
 				schema["properties"].(map[string]interface{})[key] = value
 			}
-			// Копируем обязательные поля
-			if required, ok := embeddedSchema["required"].([]string); ok {
+			// Копируем обязательные поля. Поля встроенного указателя необязательны:
+			// при nil encoding/json их не выводит
+			if required, ok := embeddedSchema["required"].([]string); ok && field.Type.Kind() != reflect.Ptr {
 				requiredFields = append(requiredFields, required...)
 			}
 			continue
@@ -111,7 +280,7 @@ func generateObjectSchema(t reflect.Type) (map[string]interface{}, error) {
 		}
 
 		// Рекурсивно генерируем схему для типа поля
-		propSchema, err := generateSchemaForType(field.Type)
+		propSchema, err := g.generate(field.Type)
 		if err != nil {
 			return nil, fmt.Errorf("ошибка в поле %s: %w", field.Name, err)
 		}
@@ -122,6 +291,13 @@ func generateObjectSchema(t reflect.Type) (map[string]interface{}, error) {
 			propSchema["description"] = desc
 		}
 
+		// Добавляем ограничения на количество элементов для массивов
+		if propSchema["type"] == "array" {
+			if err := applyArrayConstraints(propSchema, schemaTag); err != nil {
+				return nil, fmt.Errorf("ошибка в поле %s: %w", field.Name, err)
+			}
+		}
+
 		// Добавляем схему поля в общие свойства
 		schema["properties"].(map[string]interface{})[jsonName] = propSchema
 	}
@@ -133,10 +309,22 @@ func generateObjectSchema(t reflect.Type) (map[string]interface{}, error) {
 	return schema, nil
 }
 
+// isEmbeddedObject сообщает, что поля встроенного поля field нужно поднять в
+// родительский объект: это структура (или указатель на нее) без имени в json теге
+func isEmbeddedObject(field reflect.StructField) bool {
+	t := field.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	return t.Kind() == reflect.Struct && name == ""
+}
+
 // generateArraySchema создает схему для массива/среза
-func generateArraySchema(t reflect.Type) (map[string]interface{}, error) {
+func (g *schemaGenerator) generateArraySchema(t reflect.Type) (map[string]interface{}, error) {
 	// Получаем схему для типа элементов среза
-	elementSchema, err := generateSchemaForType(t.Elem())
+	elementSchema, err := g.generate(t.Elem())
 	if err != nil {
 		return nil, err
 	}
@@ -146,6 +334,75 @@ func generateArraySchema(t reflect.Type) (map[string]interface{}, error) {
 	}, nil
 }
 
+// applyArrayConstraints добавляет в схему массива minItems, maxItems и uniqueItems
+// из тега "schema" и проверяет корректность значений
+func applyArrayConstraints(schema map[string]interface{}, tag string) error {
+	minItems, hasMin, err := parseSchemaTagInt(tag, "minItems")
+	if err != nil {
+		return err
+	}
+	maxItems, hasMax, err := parseSchemaTagInt(tag, "maxItems")
+	if err != nil {
+		return err
+	}
+	if hasMin && hasMax && minItems > maxItems {
+		return fmt.Errorf("minItems (%d) больше maxItems (%d)", minItems, maxItems)
+	}
+
+	if hasMin {
+		schema["minItems"] = minItems
+	}
+	if hasMax {
+		schema["maxItems"] = maxItems
+	}
+
+	if value := parseSchemaTag(tag, "uniqueItems"); value != "" {
+		unique, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("некорректное значение uniqueItems: %q", value)
+		}
+		schema["uniqueItems"] = unique
+	}
+
+	return nil
+}
+
+// parseSchemaTagInt читает из тега "schema" неотрицательное целое значение
+func parseSchemaTagInt(tag, key string) (int, bool, error) {
+	value := parseSchemaTag(tag, key)
+	if value == "" {
+		return 0, false, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, false, fmt.Errorf("некорректное значение %s: %q", key, value)
+	}
+
+	return n, true, nil
+}
+
+// generateMapSchema создает схему для map со строковыми ключами.
+// Для map[string]interface{} ограничения на значения не добавляются
+func (g *schemaGenerator) generateMapSchema(t reflect.Type) (map[string]interface{}, error) {
+	if t.Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("неподдерживаемый тип ключа map: %s", t.Key().Kind())
+	}
+
+	schema := map[string]interface{}{"type": "object"}
+	if t.Elem().Kind() == reflect.Interface {
+		return schema, nil
+	}
+
+	valueSchema, err := g.generate(t.Elem())
+	if err != nil {
+		return nil, err
+	}
+	schema["additionalProperties"] = valueSchema
+
+	return schema, nil
+}
+
 // parseSchemaTag - простой парсер для кастомного тега "schema"
 func parseSchemaTag(tag, key string) string {
 	parts := strings.Split(tag, ";")
@@ -159,10 +416,129 @@ func parseSchemaTag(tag, key string) string {
 
 // CleanJSONResponse - очистка лишних символов перед парсингом JSON
 func cleanJSONResponse(content string) string {
-	// Remove markdown code block markers
+	return StripCodeFence(content, "json")
+}
+
+// StripCodeFence удаляет обрамляющий блок кода markdown (```lang ... ```) вокруг
+// content. Если заданы languages, метка языка после открывающего маркера удаляется,
+// только если совпадает с одной из них (без учета регистра); иначе удаляется любая
+// метка. Текст без маркеров возвращается без пробелов по краям
+func StripCodeFence(content string, languages ...string) string {
 	content = strings.TrimSpace(content)
-	content = strings.TrimPrefix(content, "```json")
-	content = strings.TrimSuffix(content, "```")
+	if !strings.HasPrefix(content, "```") {
+		return content
+	}
+
+	content = strings.TrimSuffix(content[len("```"):], "```")
+
+	if len(languages) == 0 {
+		content = strings.TrimLeftFunc(content, isFenceLanguageRune)
+	} else {
+		for _, lang := range languages {
+			if len(content) >= len(lang) && strings.EqualFold(content[:len(lang)], lang) {
+				content = content[len(lang):]
+				break
+			}
+		}
+	}
 
 	return strings.TrimSpace(content)
 }
+
+// isFenceLanguageRune сообщает, может ли символ входить в метку языка блока кода
+func isFenceLanguageRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+		r == '_' || r == '-' || r == '+' || r == '.'
+}
+
+// partialCut - позиция, до которой незавершенный JSON можно обрезать,
+// и скобки, которые нужно дописать после обрезки
+type partialCut struct {
+	pos     int
+	closers []byte
+}
+
+// completePartialJSON дополняет незавершенный JSON закрывающими кавычками и скобками.
+// Если дополненный текст невалиден (например, оборван ключ или литерал), он обрезается
+// до последнего завершенного значения. Текст до первой скобки (например, маркер
+// markdown) отбрасывается. Возвращает false, если валидный JSON получить не удалось
+func completePartialJSON(content string) (string, bool) {
+	start := strings.IndexAny(content, "{[")
+	if start < 0 {
+		return "", false
+	}
+	content = content[start:]
+
+	var stack []byte
+	var cuts []partialCut
+	inString, escaped := false, false
+
+	for i := 0; i < len(content); i++ {
+		ch := content[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch ch {
+		case '"':
+			inString = true
+		case '{', '[':
+			closer := byte('}')
+			if ch == '[' {
+				closer = ']'
+			}
+			stack = append(stack, closer)
+			cuts = append(cuts, partialCut{pos: i + 1, closers: closingBrackets(stack)})
+		case '}', ']':
+			if len(stack) == 0 || stack[len(stack)-1] != ch {
+				return "", false
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				complete := content[:i+1]
+				return complete, json.Valid([]byte(complete))
+			}
+		case ',':
+			cuts = append(cuts, partialCut{pos: i, closers: closingBrackets(stack)})
+		}
+	}
+
+	tail := content
+	if inString {
+		if escaped {
+			tail = tail[:len(tail)-1]
+		} else if idx := strings.LastIndex(tail, `\u`); idx >= 0 && len(tail)-idx < 6 {
+			tail = tail[:idx]
+		}
+		tail += `"`
+	}
+	if candidate := tail + string(closingBrackets(stack)); json.Valid([]byte(candidate)) {
+		return candidate, true
+	}
+
+	for i := len(cuts) - 1; i >= 0; i-- {
+		candidate := content[:cuts[i].pos] + string(cuts[i].closers)
+		if json.Valid([]byte(candidate)) {
+			return candidate, true
+		}
+	}
+
+	return "", false
+}
+
+// closingBrackets возвращает закрывающие скобки для стека открытых в обратном порядке
+func closingBrackets(stack []byte) []byte {
+	closers := make([]byte, len(stack))
+	for i, closer := range stack {
+		closers[len(stack)-1-i] = closer
+	}
+	return closers
+}
@@ -0,0 +1,426 @@
+package llmclient
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type schemaAddress struct {
+	City string `json:"city"`
+}
+
+type schemaPerson struct {
+	Name    string        `json:"name" schema:"description=Имя человека"`
+	Address schemaAddress `json:"address"`
+	Meta    struct {
+		Source string `json:"source"`
+	} `json:"meta"`
+}
+
+func TestGenerateSchema_Title(t *testing.T) {
+	schema, err := GenerateSchema(schemaPerson{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if schema["title"] != "schemaPerson" {
+		t.Errorf("Expected title to be 'schemaPerson', got %v", schema["title"])
+	}
+
+	properties := schema["properties"].(map[string]interface{})
+	address := properties["address"].(map[string]interface{})
+	if address["title"] != "schemaAddress" {
+		t.Errorf("Expected nested title to be 'schemaAddress', got %v", address["title"])
+	}
+
+	meta := properties["meta"].(map[string]interface{})
+	if _, ok := meta["title"]; ok {
+		t.Errorf("Expected anonymous struct to have no title, got %v", meta["title"])
+	}
+
+	schema, err = GenerateSchema(&schemaPerson{}, "Person")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if schema["title"] != "Person" {
+		t.Errorf("Expected overridden title to be 'Person', got %v", schema["title"])
+	}
+}
+
+type SchemaAudit struct {
+	CreatedBy string `json:"created_by"`
+}
+
+type SchemaTimestamps struct {
+	CreatedAt string `json:"created_at"`
+}
+
+type SchemaSource struct {
+	Channel string `json:"channel"`
+}
+
+type schemaOrder struct {
+	ID       string         `json:"id"`
+	Shipping schemaAddress  `json:"shipping,omitempty"`
+	Billing  *schemaAddress `json:"billing,omitempty"`
+	Pickup   *schemaAddress `json:"pickup"`
+	SchemaTimestamps
+	*SchemaAudit
+	SchemaSource `json:"source"`
+}
+
+// requiredOf возвращает список required схемы или nil
+func requiredOf(schema map[string]interface{}) []string {
+	required, _ := schema["required"].([]string)
+	return required
+}
+
+func TestGenerateSchema_OptionalNestedRequired(t *testing.T) {
+	schema, err := GenerateSchema(schemaOrder{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := []string{"id", "pickup", "created_at", "source"}
+	if got := requiredOf(schema); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected parent required %v, got %v", want, got)
+	}
+
+	properties := schema["properties"].(map[string]interface{})
+	for _, name := range []string{"shipping", "billing", "pickup"} {
+		nested := properties[name].(map[string]interface{})
+		if got := requiredOf(nested); !reflect.DeepEqual(got, []string{"city"}) {
+			t.Errorf("Expected %s to keep its own required list, got %v", name, got)
+		}
+	}
+
+	if _, ok := properties["created_by"]; !ok {
+		t.Error("Expected embedded pointer fields to be flattened")
+	}
+	if _, ok := properties["SchemaAudit"]; ok {
+		t.Error("Expected embedded pointer not to become a property")
+	}
+	source := properties["source"].(map[string]interface{})
+	if got := requiredOf(source); !reflect.DeepEqual(got, []string{"channel"}) {
+		t.Errorf("Expected tagged embedded type to be a nested object, got %v", source)
+	}
+}
+
+func TestGenerateSchema_ArrayConstraints(t *testing.T) {
+	type article struct {
+		Tags []string `json:"tags" schema:"description=Теги;minItems=1;maxItems=5;uniqueItems=true"`
+	}
+
+	schema, err := GenerateSchema(article{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tags := schema["properties"].(map[string]interface{})["tags"].(map[string]interface{})
+	if tags["minItems"] != 1 || tags["maxItems"] != 5 || tags["uniqueItems"] != true {
+		t.Errorf("Unexpected array constraints: %v", tags)
+	}
+	if tags["description"] != "Теги" {
+		t.Errorf("Expected description to be kept, got %v", tags["description"])
+	}
+
+	type invalidRange struct {
+		Tags []string `json:"tags" schema:"minItems=3;maxItems=2"`
+	}
+	if _, err := GenerateSchema(invalidRange{}); err == nil {
+		t.Error("Expected error for minItems > maxItems")
+	}
+
+	type negative struct {
+		Tags []string `json:"tags" schema:"maxItems=-1"`
+	}
+	if _, err := GenerateSchema(negative{}); err == nil {
+		t.Error("Expected error for negative maxItems")
+	}
+}
+
+func TestGenerateSchema_InterfaceAndMap(t *testing.T) {
+	type extraction struct {
+		Value  interface{}            `json:"value"`
+		Any    any                    `json:"any"`
+		Fields map[string]interface{} `json:"fields"`
+		Counts map[string]int         `json:"counts"`
+	}
+
+	schema, err := GenerateSchema(extraction{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	properties := schema["properties"].(map[string]interface{})
+	if value := properties["value"].(map[string]interface{}); len(value) != 0 {
+		t.Errorf("Expected empty schema for interface{}, got %v", value)
+	}
+	if value := properties["any"].(map[string]interface{}); len(value) != 0 {
+		t.Errorf("Expected empty schema for any, got %v", value)
+	}
+
+	fields := properties["fields"].(map[string]interface{})
+	if fields["type"] != "object" {
+		t.Errorf("Expected object type for map, got %v", fields["type"])
+	}
+	if _, ok := fields["additionalProperties"]; ok {
+		t.Errorf("Expected no additionalProperties for map[string]interface{}, got %v", fields["additionalProperties"])
+	}
+
+	counts := properties["counts"].(map[string]interface{})
+	additional := counts["additionalProperties"].(map[string]interface{})
+	if additional["type"] != "integer" {
+		t.Errorf("Expected integer additionalProperties, got %v", additional)
+	}
+
+	type invalidKey struct {
+		Values map[int]string `json:"values"`
+	}
+	if _, err := GenerateSchema(invalidKey{}); err == nil {
+		t.Error("Expected error for non-string map keys")
+	}
+}
+
+func TestGenerateSchema_Bytes(t *testing.T) {
+	type attachment struct {
+		Data     []byte  `json:"data"`
+		Checksum [4]byte `json:"checksum"`
+	}
+
+	schema, err := GenerateSchema(attachment{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	props := schema["properties"].(map[string]interface{})
+	data := props["data"].(map[string]interface{})
+	if data["type"] != "string" || data["contentEncoding"] != "base64" {
+		t.Errorf("Expected []byte to be a base64 string, got %v", data)
+	}
+
+	checksum := props["checksum"].(map[string]interface{})
+	if checksum["type"] != "array" {
+		t.Errorf("Expected [4]byte to stay an array as encoding/json writes it, got %v", checksum)
+	}
+
+	encoded, _ := json.Marshal(attachment{Data: []byte("hi")})
+	if err := ValidateAgainstSchema(schema, encoded); err != nil {
+		t.Errorf("Expected encoded struct to match schema, got %v", err)
+	}
+}
+
+func TestCompletePartialJSON(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		ok       bool
+	}{
+		{`{"name":"Ali`, `{"name":"Ali"}`, true},
+		{`{"name":"Alice","age":3`, `{"name":"Alice","age":3}`, true},
+		{`{"name":"Alice","ag`, `{"name":"Alice"}`, true},
+		{`{"name":"Alice","age":`, `{"name":"Alice"}`, true},
+		{`{"tags":["a","b`, `{"tags":["a","b"]}`, true},
+		{`{"ok":tr`, `{}`, true},
+		{`{"text":"line\`, `{"text":"line"}`, true},
+		{`{"text":"\u00`, `{"text":""}`, true},
+		{"```json\n{\"a\":1}\n```", `{"a":1}`, true},
+		{`{"a":{"b":[1,{"c":"d"`, `{"a":{"b":[1,{"c":"d"}]}}`, true},
+		{`no json yet`, ``, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := completePartialJSON(tt.input)
+		if ok != tt.ok || got != tt.expected {
+			t.Errorf("completePartialJSON(%q) = %q, %v; expected %q, %v", tt.input, got, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestGenerateSchema_CacheIsolation(t *testing.T) {
+	first, err := GenerateSchema(schemaPerson{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	first["title"] = "Mutated"
+	first["properties"].(map[string]interface{})["name"].(map[string]interface{})["type"] = "integer"
+	first["required"].([]string)[0] = "mutated"
+
+	second, err := GenerateSchema(&schemaPerson{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if second["title"] != "schemaPerson" {
+		t.Errorf("Expected cached title to be untouched, got %v", second["title"])
+	}
+	if typ := second["properties"].(map[string]interface{})["name"].(map[string]interface{})["type"]; typ != "string" {
+		t.Errorf("Expected cached property to be untouched, got %v", typ)
+	}
+	if second["required"].([]string)[0] == "mutated" {
+		t.Error("Expected cached required list to be untouched")
+	}
+
+	titled, _ := GenerateSchema(schemaPerson{}, "Custom")
+	if titled["title"] != "Custom" {
+		t.Errorf("Expected title override, got %v", titled["title"])
+	}
+	if again, _ := GenerateSchema(schemaPerson{}); again["title"] != "schemaPerson" {
+		t.Errorf("Expected title override not to leak into cache, got %v", again["title"])
+	}
+}
+
+func TestStripCodeFence(t *testing.T) {
+	tests := []struct {
+		content   string
+		languages []string
+		want      string
+	}{
+		{"  plain  ", nil, "plain"},
+		{"```json\n{}\n```", nil, "{}"},
+		{"```XML\n<a/>\n```", []string{"xml"}, "<a/>"},
+		{"```yaml\na: 1\n```", []string{"json"}, "yaml\na: 1"},
+		{"```\nkey,value\n```", nil, "key,value"},
+	}
+	for _, tt := range tests {
+		if got := StripCodeFence(tt.content, tt.languages...); got != tt.want {
+			t.Errorf("StripCodeFence(%q, %v) = %q, want %q", tt.content, tt.languages, got, tt.want)
+		}
+	}
+}
+
+func BenchmarkGenerateSchema(b *testing.B) {
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := GenerateSchema(schemaPerson{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("uncached", func(b *testing.B) {
+		t := reflect.TypeOf(schemaPerson{})
+		for i := 0; i < b.N; i++ {
+			if _, err := generateSchemaForType(t); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+type schemaNode struct {
+	Value int         `json:"value"`
+	Next  *schemaNode `json:"next,omitempty"`
+}
+
+type schemaList struct {
+	Head  *schemaNode `json:"head"`
+	Count **int       `json:"count"`
+}
+
+func TestGenerateSchema_PointerChainsAndCycles(t *testing.T) {
+	schema, err := GenerateSchema(schemaNode{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	next := schema["properties"].(map[string]interface{})["next"].(map[string]interface{})
+	if next["$ref"] != "#" {
+		t.Errorf("Expected self reference to the root, got %v", next)
+	}
+
+	list := &schemaList{}
+	schema, err = GenerateSchema(&list)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	properties := schema["properties"].(map[string]interface{})
+	if count := properties["count"].(map[string]interface{}); count["type"] != "integer" {
+		t.Errorf("Expected **int to be an integer, got %v", count)
+	}
+	if head := properties["head"].(map[string]interface{}); head["$ref"] != "#/$defs/schemaNode" {
+		t.Errorf("Expected reference to $defs, got %v", head)
+	}
+
+	node := schema["$defs"].(map[string]interface{})["schemaNode"].(map[string]interface{})
+	nodeNext := node["properties"].(map[string]interface{})["next"].(map[string]interface{})
+	if node["type"] != "object" || nodeNext["$ref"] != "#/$defs/schemaNode" {
+		t.Errorf("Unexpected definition: %v", node)
+	}
+
+	if _, err := GenerateSchema(nil); err == nil {
+		t.Error("Expected error for nil instance")
+	}
+}
+
+// SchemaTreeNode экспортирован, чтобы встраивание не пропускалось как неэкспортируемое поле
+type SchemaTreeNode struct {
+	Name     string            `json:"name"`
+	Children []*SchemaTreeNode `json:"children,omitempty"`
+}
+
+type schemaTree struct {
+	SchemaTreeNode
+	ID int `json:"id"`
+}
+
+func TestGenerateSchema_RecursiveEmbedded(t *testing.T) {
+	schema, err := GenerateSchema(schemaTree{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	properties := schema["properties"].(map[string]interface{})
+	if _, ok := properties["name"]; !ok {
+		t.Errorf("Expected embedded properties to be inlined, got %v", properties)
+	}
+	children := properties["children"].(map[string]interface{})
+	if items := children["items"].(map[string]interface{}); items["$ref"] != "#/$defs/SchemaTreeNode" {
+		t.Errorf("Expected reference to $defs, got %v", items)
+	}
+
+	if err := ValidateAgainstSchema(schema, []byte(`{"id":1,"name":"root","children":[{"name":"leaf"}]}`)); err != nil {
+		t.Errorf("Unexpected validation error: %v", err)
+	}
+	if err := ValidateAgainstSchema(schema, []byte(`{"id":1,"name":"root","children":[{"name":2}]}`)); err == nil {
+		t.Error("Expected validation error for nested child")
+	}
+}
+
+func TestGenerateSchema_SameNamedDefs(t *testing.T) {
+	// Локальный тип с тем же именем, что и schemaNode уровня пакета
+	type schemaNode struct {
+		Label string      `json:"label"`
+		Next  *schemaNode `json:"next,omitempty"`
+	}
+	type pair struct {
+		A *schemaNode      `json:"a"`
+		B *schemaNodeAlias `json:"b"`
+	}
+
+	schema, err := GenerateSchema(pair{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	defs := schema["$defs"].(map[string]interface{})
+	if len(defs) != 2 {
+		t.Fatalf("Expected 2 definitions, got %v", defs)
+	}
+	properties := schema["properties"].(map[string]interface{})
+	refA := properties["a"].(map[string]interface{})["$ref"]
+	refB := properties["b"].(map[string]interface{})["$ref"]
+	if refA == refB {
+		t.Errorf("Expected distinct references, got %v and %v", refA, refB)
+	}
+
+	if err := ValidateAgainstSchema(schema, []byte(`{"a":{"label":"x","next":{"label":"y"}},"b":{"value":1,"next":{"value":2}}}`)); err != nil {
+		t.Errorf("Unexpected validation error: %v", err)
+	}
+	if err := ValidateAgainstSchema(schema, []byte(`{"a":{"label":"x"},"b":{"value":1,"next":{"label":"y"}}}`)); err == nil {
+		t.Error("Expected validation error for mismatched definition")
+	}
+}
+
+// schemaNodeAlias ссылается на schemaNode уровня пакета там, где имя перекрыто локальным типом
+type schemaNodeAlias = schemaNode
@@ -0,0 +1,340 @@
+package llmclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidateAgainstSchema проверяет JSON data по схеме без обращения к модели.
+// Поддерживается подмножество JSON Schema, которое строит GenerateSchema, а также
+// ограничения, обычно добавляемые вручную: type, properties, required,
+// additionalProperties, items, minItems, maxItems, uniqueItems, enum, minimum,
+// maximum, minLength и maxLength. Ссылки $ref вида "#" и "#/$defs/Name" (рекурсивные
+// типы GenerateSchema) разрешаются относительно schema. Возвращает первое найденное
+// нарушение с путем к значению
+func ValidateAgainstSchema(schema map[string]interface{}, data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if decoder.More() {
+		return fmt.Errorf("invalid JSON: unexpected data after top-level value")
+	}
+
+	return schemaValidator{root: schema}.validateValue("$", schema, value)
+}
+
+// maxRefChain - наибольшая длина цепочки ссылок $ref, ведущих друг на друга
+const maxRefChain = 32
+
+// schemaValidator проверяет значения по схеме, разрешая $ref относительно root
+type schemaValidator struct {
+	root map[string]interface{}
+}
+
+// resolve заменяет схему со ссылкой $ref схемой, на которую она указывает
+func (v schemaValidator) resolve(path string, schema map[string]interface{}) (map[string]interface{}, error) {
+	for i := 0; ; i++ {
+		ref, ok := schema["$ref"].(string)
+		if !ok {
+			return schema, nil
+		}
+		if i == maxRefChain {
+			return nil, fmt.Errorf("%s: $ref chain is too long", path)
+		}
+
+		target, ok := v.lookup(ref)
+		if !ok {
+			return nil, fmt.Errorf("%s: unresolved $ref %q", path, ref)
+		}
+		schema = target
+	}
+}
+
+// lookup находит схему по ссылке "#" или "#/$defs/Name" ("#/definitions/Name")
+func (v schemaValidator) lookup(ref string) (map[string]interface{}, bool) {
+	if ref == "#" {
+		return v.root, true
+	}
+
+	pointer, ok := strings.CutPrefix(ref, "#/")
+	if !ok {
+		return nil, false
+	}
+	section, name, ok := strings.Cut(pointer, "/")
+	if !ok || (section != "$defs" && section != "definitions") {
+		return nil, false
+	}
+
+	defs, _ := v.root[section].(map[string]interface{})
+	name = strings.NewReplacer("~1", "/", "~0", "~").Replace(name)
+	schema, ok := defs[name].(map[string]interface{})
+	return schema, ok
+}
+
+// validateValue рекурсивно проверяет значение по схеме
+func (v schemaValidator) validateValue(path string, schema map[string]interface{}, value interface{}) error {
+	schema, err := v.resolve(path, schema)
+	if err != nil {
+		return err
+	}
+
+	if err := validateType(path, schema["type"], value); err != nil {
+		return err
+	}
+
+	if enum, ok := schema["enum"]; ok {
+		if err := validateEnum(path, enum, value); err != nil {
+			return err
+		}
+	}
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		return v.validateObject(path, schema, val)
+	case []interface{}:
+		return v.validateArray(path, schema, val)
+	case json.Number:
+		return validateNumber(path, schema, val)
+	case string:
+		return validateString(path, schema, val)
+	}
+
+	return nil
+}
+
+// validateType проверяет ключевое слово type, заданное строкой или списком строк
+func validateType(path string, typ interface{}, value interface{}) error {
+	var types []string
+	switch t := typ.(type) {
+	case nil:
+		return nil
+	case string:
+		types = []string{t}
+	case []string:
+		types = t
+	case []interface{}:
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+	default:
+		return fmt.Errorf("%s: unsupported type keyword %v", path, typ)
+	}
+
+	actual := jsonTypeOf(value)
+	for _, expected := range types {
+		if expected == actual || (expected == "number" && actual == "integer") {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s: expected %s, got %s", path, joinTypes(types), actual)
+}
+
+// jsonTypeOf возвращает тип JSON Schema для декодированного значения
+func jsonTypeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// joinTypes форматирует список допустимых типов для сообщения об ошибке
+func joinTypes(types []string) string {
+	if len(types) == 1 {
+		return types[0]
+	}
+	return fmt.Sprintf("one of %v", types)
+}
+
+// validateObject проверяет required, properties и additionalProperties
+func (v schemaValidator) validateObject(path string, schema map[string]interface{}, obj map[string]interface{}) error {
+	for _, name := range stringList(schema["required"]) {
+		if _, ok := obj[name]; !ok {
+			return fmt.Errorf("%s: missing required property %q", path, name)
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		propPath := path + "." + key
+		if propSchema, ok := properties[key].(map[string]interface{}); ok {
+			if err := v.validateValue(propPath, propSchema, obj[key]); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fmt.Errorf("%s: additional property is not allowed", propPath)
+			}
+		case map[string]interface{}:
+			if err := v.validateValue(propPath, additional, obj[key]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateArray проверяет items, minItems, maxItems и uniqueItems
+func (v schemaValidator) validateArray(path string, schema map[string]interface{}, arr []interface{}) error {
+	if min, ok := schemaNumber(schema["minItems"]); ok && float64(len(arr)) < min {
+		return fmt.Errorf("%s: expected at least %v items, got %d", path, min, len(arr))
+	}
+	if max, ok := schemaNumber(schema["maxItems"]); ok && float64(len(arr)) > max {
+		return fmt.Errorf("%s: expected at most %v items, got %d", path, max, len(arr))
+	}
+
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := range arr {
+			for j := 0; j < i; j++ {
+				if jsonEqual(arr[i], arr[j]) {
+					return fmt.Errorf("%s: items %d and %d are equal", path, j, i)
+				}
+			}
+		}
+	}
+
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		for i, item := range arr {
+			if err := v.validateValue(path+"["+strconv.Itoa(i)+"]", items, item); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateNumber проверяет minimum и maximum
+func validateNumber(path string, schema map[string]interface{}, n json.Number) error {
+	f, err := n.Float64()
+	if err != nil {
+		return fmt.Errorf("%s: invalid number %s", path, n)
+	}
+
+	if min, ok := schemaNumber(schema["minimum"]); ok && f < min {
+		return fmt.Errorf("%s: %s is less than minimum %v", path, n, min)
+	}
+	if max, ok := schemaNumber(schema["maximum"]); ok && f > max {
+		return fmt.Errorf("%s: %s is greater than maximum %v", path, n, max)
+	}
+
+	return nil
+}
+
+// validateString проверяет minLength и maxLength (в символах)
+func validateString(path string, schema map[string]interface{}, s string) error {
+	length := float64(utf8.RuneCountInString(s))
+
+	if min, ok := schemaNumber(schema["minLength"]); ok && length < min {
+		return fmt.Errorf("%s: expected at least %v characters, got %v", path, min, length)
+	}
+	if max, ok := schemaNumber(schema["maxLength"]); ok && length > max {
+		return fmt.Errorf("%s: expected at most %v characters, got %v", path, max, length)
+	}
+
+	return nil
+}
+
+// validateEnum проверяет, что значение совпадает с одним из допустимых
+func validateEnum(path string, enum interface{}, value interface{}) error {
+	allowed := reflect.ValueOf(enum)
+	if allowed.Kind() != reflect.Slice {
+		return fmt.Errorf("%s: unsupported enum keyword %v", path, enum)
+	}
+
+	for i := 0; i < allowed.Len(); i++ {
+		if jsonEqual(value, allowed.Index(i).Interface()) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s: value %v is not one of %v", path, value, enum)
+}
+
+// jsonEqual сравнивает значения по их JSON представлению, поэтому
+// json.Number и числа Go с одинаковым значением считаются равными
+func jsonEqual(a, b interface{}) bool {
+	if fa, ok := schemaNumber(a); ok {
+		fb, ok := schemaNumber(b)
+		return ok && fa == fb
+	}
+
+	da, errA := json.Marshal(a)
+	db, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(da, db)
+}
+
+// schemaNumber приводит числовое значение схемы или данных к float64
+func schemaNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// stringList приводит значение required ([]string или []interface{}) к []string
+func stringList(v interface{}) []string {
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		result := make([]string, 0, len(list))
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
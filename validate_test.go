@@ -0,0 +1,115 @@
+package llmclient
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateAgainstSchema(t *testing.T) {
+	type address struct {
+		City string `json:"city"`
+	}
+	type person struct {
+		Name    string            `json:"name"`
+		Age     int               `json:"age"`
+		Score   float64           `json:"score,omitempty"`
+		Tags    []string          `json:"tags,omitempty" schema:"maxItems=2;uniqueItems=true"`
+		Address *address          `json:"address,omitempty"`
+		Labels  map[string]string `json:"labels,omitempty"`
+	}
+
+	schema, err := GenerateSchema(person{})
+	if err != nil {
+		t.Fatalf("Failed to generate schema: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		errPart string
+	}{
+		{"valid", `{"name":"Alice","age":30,"score":1.5,"tags":["a","b"],"address":{"city":"Oslo"},"labels":{"k":"v"}}`, ""},
+		{"integer as float", `{"name":"Alice","age":30.0}`, ""},
+		{"missing required", `{"name":"Alice"}`, `$: missing required property "age"`},
+		{"wrong type", `{"name":"Alice","age":"30"}`, "$.age: expected integer, got string"},
+		{"fractional integer", `{"name":"Alice","age":30.5}`, "$.age: expected integer, got number"},
+		{"too many items", `{"name":"Alice","age":30,"tags":["a","b","c"]}`, "$.tags: expected at most 2 items"},
+		{"duplicate items", `{"name":"Alice","age":30,"tags":["a","a"]}`, "$.tags: items 0 and 1 are equal"},
+		{"nested", `{"name":"Alice","age":30,"address":{"city":1}}`, "$.address.city: expected string"},
+		{"map values", `{"name":"Alice","age":30,"labels":{"k":1}}`, "$.labels.k: expected string"},
+		{"invalid JSON", `{"name":`, "invalid JSON"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAgainstSchema(schema, []byte(tt.data))
+			if tt.errPart == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errPart) {
+				t.Errorf("Expected error containing %q, got %v", tt.errPart, err)
+			}
+		})
+	}
+}
+
+func TestValidateAgainstSchema_Keywords(t *testing.T) {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"level": map[string]interface{}{"type": "string", "enum": []string{"low", "high"}},
+			"count": map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 10},
+			"code":  map[string]interface{}{"type": "string", "minLength": 2, "maxLength": 3},
+		},
+		"additionalProperties": false,
+	}
+
+	tests := []struct {
+		data    string
+		errPart string
+	}{
+		{`{"level":"low","count":5,"code":"ab"}`, ""},
+		{`{"level":"mid"}`, "$.level: value mid is not one of"},
+		{`{"count":0}`, "$.count: 0 is less than minimum 1"},
+		{`{"count":11}`, "$.count: 11 is greater than maximum 10"},
+		{`{"code":"abcd"}`, "$.code: expected at most 3 characters"},
+		{`{"extra":true}`, "$.extra: additional property is not allowed"},
+	}
+
+	for _, tt := range tests {
+		err := ValidateAgainstSchema(schema, []byte(tt.data))
+		if tt.errPart == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.data, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.errPart) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.data, tt.errPart, err)
+		}
+	}
+}
+
+func TestValidateAgainstSchema_Refs(t *testing.T) {
+	schema, err := GenerateSchema(schemaNode{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := ValidateAgainstSchema(schema, []byte(`{"value":1,"next":{"value":2}}`)); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	err = ValidateAgainstSchema(schema, []byte(`{"value":1,"next":{"value":"bad","next":5}}`))
+	if err == nil || !strings.Contains(err.Error(), "$.next.") {
+		t.Errorf("Expected error in nested node, got %v", err)
+	}
+
+	err = ValidateAgainstSchema(map[string]interface{}{"$ref": "#/$defs/missing"}, []byte(`{}`))
+	if err == nil || !strings.Contains(err.Error(), "unresolved $ref") {
+		t.Errorf("Expected unresolved $ref error, got %v", err)
+	}
+}